@@ -0,0 +1,139 @@
+package ep
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrAdmission is returned by distributed Runners that were rejected by the
+// admission control of a peer. See Admission
+var ErrAdmission = errors.New("ep: runner rejected by admission control")
+
+var _ = Errors.Register("admission", ErrAdmission)
+
+// Admission limits the number of Runners received from other nodes that run
+// concurrently on a node, in order to avoid exhausting its resources during
+// bursts of queries. Runners exceeding the limit wait in a queue, ordered by
+// their priority (see SetPriority), until a running one completes. Runners
+// that can't be admitted are rejected with ErrAdmission, which fails the query
+// on the master node. As a distributed Runner starts only after all of its
+// peers admitted it, admitted peers hold their slots while others are queued,
+// so QueueTimeout should be set when multiple masters share the same peers.
+type Admission struct {
+	// MaxRunners is the maximum number of concurrently running Runners. Zero
+	// means unlimited
+	MaxRunners int
+
+	// MaxQueued is the maximum number of Runners waiting for admission. Runners
+	// that arrive when the queue is full are rejected immediately
+	MaxQueued int
+
+	// QueueTimeout is the maximum duration for a Runner to wait in the queue
+	// before it's rejected. Zero means no timeout
+	QueueTimeout time.Duration
+}
+
+// admitter implements Admission. A nil admitter admits everything
+type admitter struct {
+	Admission
+	l       sync.Mutex
+	running int
+	queue   []*ticket // sorted by priority, then by arrival
+}
+
+// ticket is a queued request for admission
+type ticket struct {
+	priority int
+	ready    chan struct{} // closed when admitted
+}
+
+func newAdmitter(a Admission) *admitter {
+	return &admitter{Admission: a}
+}
+
+// admit blocks until a Runner with the given priority can run, or returns
+// an error if it's rejected. Admitted Runners must call release() when done
+func (a *admitter) admit(priority int) error {
+	if a == nil || a.MaxRunners <= 0 {
+		return nil
+	}
+
+	a.l.Lock()
+	if a.running < a.MaxRunners {
+		a.running++
+		a.l.Unlock()
+		return nil
+	}
+
+	if len(a.queue) >= a.MaxQueued {
+		a.l.Unlock()
+		return fmt.Errorf("%w: %d runners running, queue is full", ErrAdmission, a.running)
+	}
+
+	t := &ticket{priority, make(chan struct{})}
+	a.enqueue(t)
+	a.l.Unlock()
+
+	var timeout <-chan time.Time
+	if a.QueueTimeout > 0 {
+		timer := time.NewTimer(a.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-t.ready:
+		return nil
+	case <-timeout:
+	}
+
+	a.l.Lock()
+	defer a.l.Unlock()
+	if !a.dequeue(t) {
+		return nil // admitted right before the timeout
+	}
+	return fmt.Errorf("%w: queue timeout after %s", ErrAdmission, a.QueueTimeout)
+}
+
+// release frees the slot of an admitted Runner, passing it over to the next
+// queued one, if any
+func (a *admitter) release() {
+	if a == nil || a.MaxRunners <= 0 {
+		return
+	}
+
+	a.l.Lock()
+	defer a.l.Unlock()
+	if len(a.queue) == 0 {
+		a.running--
+		return
+	}
+
+	next := a.queue[0]
+	a.queue = a.queue[1:]
+	close(next.ready)
+}
+
+// enqueue adds t to the queue after all tickets with the same or higher priority
+func (a *admitter) enqueue(t *ticket) {
+	i := len(a.queue)
+	for i > 0 && a.queue[i-1].priority < t.priority {
+		i--
+	}
+	a.queue = append(a.queue, nil)
+	copy(a.queue[i+1:], a.queue[i:])
+	a.queue[i] = t
+}
+
+// dequeue removes t from the queue, and returns false if it's not queued
+func (a *admitter) dequeue(t *ticket) bool {
+	for i, queued := range a.queue {
+		if queued == t {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
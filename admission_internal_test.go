@@ -0,0 +1,182 @@
+package ep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAdmitter_unlimited(t *testing.T) {
+	var a *admitter
+	require.NoError(t, a.admit(0))
+	a.release()
+
+	a = newAdmitter(Admission{})
+	for i := 0; i < 10; i++ {
+		require.NoError(t, a.admit(0))
+	}
+}
+
+func TestAdmitter_queueFull(t *testing.T) {
+	a := newAdmitter(Admission{MaxRunners: 1})
+	require.NoError(t, a.admit(0))
+
+	err := a.admit(0)
+	require.True(t, errors.Is(err, ErrAdmission))
+	require.Equal(t, "ep: runner rejected by admission control: 1 runners running, queue is full", err.Error())
+
+	a.release()
+	require.NoError(t, a.admit(0))
+}
+
+func TestAdmitter_queueTimeout(t *testing.T) {
+	a := newAdmitter(Admission{MaxRunners: 1, MaxQueued: 1, QueueTimeout: 10 * time.Millisecond})
+	require.NoError(t, a.admit(0))
+
+	err := a.admit(0)
+	require.True(t, errors.Is(err, ErrAdmission))
+	require.Empty(t, a.queue)
+}
+
+func TestAdmitter_priorities(t *testing.T) {
+	a := newAdmitter(Admission{MaxRunners: 1, MaxQueued: 3})
+	require.NoError(t, a.admit(0))
+
+	admitted := make(chan int, 3)
+	for _, priority := range []int{1, 5, 3} {
+		go func(priority int) {
+			require.NoError(t, a.admit(priority))
+			admitted <- priority
+		}(priority)
+
+		// wait for it to be queued, to guarantee arrival order
+		for queued := 0; queued == 0; {
+			a.l.Lock()
+			for _, t := range a.queue {
+				if t.priority == priority {
+					queued++
+				}
+			}
+			a.l.Unlock()
+		}
+	}
+
+	var order []int
+	for i := 0; i < 3; i++ {
+		a.release()
+		order = append(order, <-admitted)
+	}
+	require.Equal(t, []int{5, 3, 1}, order)
+}
+
+func TestDistributer_admission(t *testing.T) {
+	master := startCluster(t, ":5551")[0]
+	ln, err := net.Listen("tcp", ":5552")
+	require.NoError(t, err)
+	peer := NewDistributer(":5552", ln, WithAdmission(Admission{MaxRunners: 1}))
+	defer terminateCluster(t, master, peer)
+
+	// occupy the only slot on the peer
+	require.NoError(t, peer.(*distributer).admission.admit(0))
+
+	runner := master.Distribute(Pipeline(&blockUntilCanceled{}, Gather()), ":5551", ":5552")
+	inp := make(chan Dataset)
+	out := make(chan Dataset)
+	close(inp)
+	go drain(out)
+
+	Run(context.Background(), runner, inp, out, nil, &err)
+	require.True(t, errors.Is(err, ErrAdmission))
+
+	var remoteErr *RemoteError
+	require.True(t, errors.As(err, &remoteErr))
+	require.Equal(t, ":5552", remoteErr.Node)
+}
+
+// startAdmissionCluster starts a master without admission control, and peers
+// with the provided Admission
+func startAdmissionCluster(t *testing.T, a Admission, master string, peers ...string) []Distributer {
+	res := startCluster(t, master)
+	for _, port := range peers {
+		ln, err := net.Listen("tcp", port)
+		require.NoError(t, err)
+		res = append(res, NewDistributer(port, ln, WithAdmission(a)))
+	}
+	return res
+}
+
+func TestDistributer_admission_queuedPeer(t *testing.T) {
+	dists := startAdmissionCluster(t, Admission{MaxRunners: 1, MaxQueued: 10}, ":5551", ":5552", ":5553")
+	defer terminateCluster(t, dists...)
+
+	// occupy the only slot of one of the peers for longer than the connect
+	// timeout of exchanges, while the other peer admits the runner immediately
+	queued := dists[2].(*distributer).admission
+	require.NoError(t, queued.admit(0))
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		queued.release()
+	}()
+
+	runner := dists[0].Distribute(Pipeline(Scatter(), Gather()), ":5551", ":5552", ":5553")
+	inp := make(chan Dataset, 4)
+	out := make(chan Dataset)
+	for _, s := range []string{"hello", "world", "foo", "bar"} {
+		inp <- NewDataset(strs{s}) // single rows, as strs can't be sliced
+	}
+	close(inp)
+
+	var err error
+	done := make(chan []string)
+	go func() {
+		var res []string
+		for data := range out {
+			res = append(res, data.At(0).Strings()...)
+		}
+		done <- res
+	}()
+
+	Run(context.Background(), runner, inp, out, nil, &err)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"hello", "world", "foo", "bar"}, <-done)
+}
+
+func TestDistributer_admission_abortAdmittedPeers(t *testing.T) {
+	dists := startAdmissionCluster(t, Admission{MaxRunners: 1}, ":5551", ":5552", ":5553")
+	defer terminateCluster(t, dists...)
+
+	// the last peer rejects the runner, while the first one admits it
+	rejecting := dists[2].(*distributer).admission
+	require.NoError(t, rejecting.admit(0))
+	defer rejecting.release()
+
+	runner := dists[0].Distribute(Pipeline(&blockUntilCanceled{}, Gather()), ":5551", ":5552", ":5553")
+	inp := make(chan Dataset)
+	out := make(chan Dataset)
+	close(inp)
+	go drain(out)
+
+	// the admitted peer is aborted without running, rather than waiting on
+	// its exchanges until they time out, and frees its slot
+	var err error
+	start := time.Now()
+	Run(context.Background(), runner, inp, out, nil, &err)
+	require.True(t, errors.Is(err, ErrAdmission))
+	require.True(t, time.Since(start) < 500*time.Millisecond)
+
+	admitted := dists[1].(*distributer).admission
+	for i := 0; i < 100 && !isIdle(admitted); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, isIdle(admitted))
+	waitForRunning(t, dists[1], 0)
+}
+
+func isIdle(a *admitter) bool {
+	a.l.Lock()
+	defer a.l.Unlock()
+	return a.running == 0
+}
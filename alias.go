@@ -62,6 +62,8 @@ func (a *alias) Push(toPush ScopesRunner) bool {
 	return false
 }
 
+func (a *alias) Idempotent() bool { return IsIdempotent(a.Runner) }
+
 // SetAlias sets an alias for the given typed column.
 // Useful for runner that need aliasing each column internally
 func SetAlias(col Type, alias string) Type {
@@ -130,6 +132,8 @@ func (s *scope) ApproxSize() int {
 	return UnknownSize
 }
 
+func (s *scope) Idempotent() bool { return IsIdempotent(s.Runner) }
+
 // SetScope sets a scope for the given columns
 func SetScope(cols []Type, scope string) []Type {
 	if scope == "" {
@@ -25,6 +25,7 @@ func (b *batch) Equals(other interface{}) bool {
 }
 
 func (b *batch) Returns() []Type { return []Type{Wildcard} }
+func (*batch) Idempotent() bool  { return true }
 func (b *batch) Run(ctx context.Context, inp, out chan Dataset) error {
 	buffer := NewDatasetBuilder()
 	var bufferLen int
@@ -0,0 +1,156 @@
+package ep
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// cast is an implicit conversion registered by Types.RegisterCast
+type cast struct {
+	To     Type
+	Runner Runner
+	Cost   float64
+}
+
+// RegisterCast registers a runner that implicitly converts a single column of
+// the from type into the to type, at the provided non-negative cost. Casts
+// allow PlanWithArgs to plan runners whose Args() don't match the provided
+// args, by converting the args through the cheapest sequence of casts.
+func (reg typesReg) RegisterCast(from, to Type, r Runner, cost float64) typesReg {
+	if cost < 0 {
+		panic("ep: negative cast cost")
+	}
+
+	registerGob(r)
+	reg.reg.l.Lock()
+	defer reg.reg.l.Unlock()
+	reg.reg.casts[from.Name()] = append(reg.reg.casts[from.Name()], cast{to, r, cost})
+	atomic.AddUint64(&runnersVersion, 1)
+	return reg
+}
+
+// castPath returns the cheapest sequence of casts from one type to another,
+// and its total cost. It returns false if there's no such sequence
+func (reg *Registry) castPath(from, to Type) ([]Runner, float64, bool) {
+	if AreEqualTypes([]Type{from}, []Type{to}) {
+		return nil, 0, true
+	}
+
+	reg.l.RLock()
+	defer reg.l.RUnlock()
+
+	// Dijkstra's shortest path over the type names
+	type step struct {
+		prev string
+		cast cast
+	}
+	costs := map[string]float64{from.Name(): 0}
+	steps := map[string]step{}
+	visited := map[string]bool{}
+	for {
+		name, found := "", false
+		for n, cost := range costs {
+			if !visited[n] && (!found || cost < costs[name]) {
+				name, found = n, true
+			}
+		}
+
+		if !found {
+			return nil, 0, false
+		}
+		if name == to.Name() {
+			break
+		}
+
+		visited[name] = true
+		for _, c := range reg.casts[name] {
+			cost := costs[name] + c.Cost
+			if prev, ok := costs[c.To.Name()]; !ok || cost < prev {
+				costs[c.To.Name()] = cost
+				steps[c.To.Name()] = step{name, c}
+			}
+		}
+	}
+
+	var path []Runner
+	for name := to.Name(); name != from.Name(); name = steps[name].prev {
+		path = append([]Runner{steps[name].cast.Runner}, path...)
+	}
+	return path, costs[to.Name()], true
+}
+
+// overload is a runner registered for the planned key, along with the casts
+// of each of the args needed in order to match its Args()
+type overload struct {
+	Runner Runner
+	Casts  [][]Runner
+	Cost   float64
+}
+
+// overloads returns the runners registered to the key, whose Args() match the
+// args directly or through casts, from the cheapest to the most expensive,
+// along with the runners that don't match the args
+func (reg *Registry) overloads(k interface{}, args []Type) ([]overload, []RejectedRunner) {
+	var res []overload
+	var rejected []RejectedRunner
+	for _, r := range reg.Runners.Get(k) {
+		withArgs, ok := r.(RunnerArgs)
+		if !ok {
+			rejected = append(rejected, RejectedRunner{r, errNoArgs})
+			continue
+		}
+
+		params := withArgs.Args()
+		if AreEqualTypes(params, args) {
+			res = append(res, overload{Runner: r})
+			continue
+		}
+
+		mismatch := fmt.Errorf("expects args %s but receives %s", params, args)
+		if len(params) != len(args) {
+			rejected = append(rejected, RejectedRunner{r, mismatch})
+			continue
+		}
+
+		o := overload{Runner: r, Casts: make([][]Runner, len(args))}
+		for i, arg := range args {
+			path, cost, ok := reg.castPath(arg, params[i])
+			if !ok {
+				o.Casts = nil
+				break
+			}
+			o.Casts[i] = path
+			o.Cost += cost
+		}
+		if o.Casts != nil {
+			res = append(res, o)
+		} else {
+			rejected = append(rejected, RejectedRunner{r, mismatch})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Cost == res[j].Cost {
+			return res[i].Casts == nil && res[j].Casts != nil
+		}
+		return res[i].Cost < res[j].Cost
+	})
+	return res, rejected
+}
+
+var errNoArgs = errors.New("doesn't declare its args")
+
+// wrap returns the planned runner, preceded by the casts of its input
+func (o *overload) wrap(r Runner) Runner {
+	if o.Casts == nil {
+		return r
+	}
+
+	cols := make([]Runner, len(o.Casts))
+	for i, path := range o.Casts {
+		cols[i] = Pipeline(append([]Runner{Pick(i)}, path...)...)
+	}
+	return Pipeline(Project(cols...), r)
+}
@@ -0,0 +1,124 @@
+package ep_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// namedType is a type that's only distinguished by its name
+type namedType struct {
+	ep.Type
+	name string
+}
+
+func (t *namedType) Name() string   { return t.name }
+func (t *namedType) String() string { return t.name }
+
+var int2, int4, int8 = &namedType{integer, "int2"}, &namedType{integer, "int4"}, &namedType{integer, "int8"}
+
+// castRunner converts integers to strings, while declaring the types it
+// converts between
+type castRunner struct{ From, To ep.Type }
+
+func (r *castRunner) Equals(other interface{}) bool {
+	o, ok := other.(*castRunner)
+	return ok && r.From.Name() == o.From.Name() && r.To.Name() == o.To.Name()
+}
+
+func (r *castRunner) Args() []ep.Type    { return []ep.Type{r.From} }
+func (r *castRunner) Returns() []ep.Type { return []ep.Type{r.To} }
+func (*castRunner) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		res := make(strs, data.Len())
+		for i, v := range data.At(0).(integers) {
+			res[i] = fmt.Sprint(v)
+		}
+		out <- ep.NewDataset(res)
+	}
+	return nil
+}
+
+// sum is a runner registered for several argument types
+type sum struct{ Arg ep.Type }
+
+func (r *sum) Equals(other interface{}) bool {
+	o, ok := other.(*sum)
+	return ok && r.Arg.Name() == o.Arg.Name()
+}
+
+func (r *sum) Args() []ep.Type                                     { return []ep.Type{r.Arg} }
+func (r *sum) Returns() []ep.Type                                  { return []ep.Type{r.Arg} }
+func (*sum) Run(_ context.Context, inp, out chan ep.Dataset) error { return nil }
+
+// concat concatenates two string columns
+type concat struct{}
+
+func (*concat) Equals(other interface{}) bool { _, ok := other.(*concat); return ok }
+func (*concat) Args() []ep.Type               { return []ep.Type{str, str} }
+func (*concat) Returns() []ep.Type            { return []ep.Type{str} }
+func (*concat) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		res := make(strs, data.Len())
+		for i := range res {
+			res[i] = data.At(0).Strings()[i] + data.At(1).Strings()[i]
+		}
+		out <- ep.NewDataset(res)
+	}
+	return nil
+}
+
+func TestPlanWithArgs_casts(t *testing.T) {
+	reg := ep.NewRegistry()
+	reg.Types.
+		RegisterCast(int2, int4, &castRunner{int2, int4}, 1).
+		RegisterCast(int4, int8, &castRunner{int4, int8}, 1).
+		RegisterCast(int2, int8, &castRunner{int2, int8}, 5)
+	reg.Runners.Register("SUM", &sum{int8})
+	ctx := ep.WithRegistry(context.Background(), reg)
+
+	// cheapest sequence of casts
+	r, err := ep.PlanWithArgs(ctx, "SUM", []ep.Type{int2})
+	require.NoError(t, err)
+	expected := ep.Pipeline(
+		ep.Project(ep.Pipeline(ep.Pick(0), &castRunner{int2, int4}, &castRunner{int4, int8})),
+		&sum{int8},
+	)
+	require.True(t, expected.Equals(r), ep.Explain(r))
+
+	// exact matches are preferred
+	reg.Runners.Register("SUM", &sum{int4})
+	r, err = ep.PlanWithArgs(ctx, "SUM", []ep.Type{int4})
+	require.NoError(t, err)
+	require.True(t, r.Equals(&sum{int4}), ep.Explain(r))
+
+	// over cheaper overloads
+	r, err = ep.PlanWithArgs(ctx, "SUM", []ep.Type{int2})
+	require.NoError(t, err)
+	expected = ep.Pipeline(ep.Project(ep.Pipeline(ep.Pick(0), &castRunner{int2, int4})), &sum{int4})
+	require.True(t, expected.Equals(r), ep.Explain(r))
+
+	// no casts back to smaller types
+	_, err = ep.PlanWithArgs(ctx, "SUM", []ep.Type{str})
+	require.Error(t, err)
+	require.Empty(t, reg.Runners.GetWithArgs("SUM", []ep.Type{int2}))
+}
+
+func TestPlanWithArgs_castsRun(t *testing.T) {
+	reg := ep.NewRegistry()
+	reg.Types.RegisterCast(integer, str, &castRunner{integer, str}, 1)
+	reg.Runners.Register("concat", &concat{})
+	ctx := ep.WithRegistry(context.Background(), reg)
+
+	r, err := ep.PlanWithArgs(ctx, "concat", []ep.Type{integer, str})
+	require.NoError(t, err)
+	require.NoError(t, ep.Validate(r))
+
+	data := ep.NewDataset(integers{1, 2}, strs{"a", "b"})
+	res, err := eptest.Run(r, data)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1a", "2b"}, res.At(0).Strings())
+}
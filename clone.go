@@ -0,0 +1,149 @@
+package ep
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/satori/go.uuid"
+)
+
+// Cloner is a Runner that also copies itself, see Clone. Runners that wrap
+// other runners, or that can't be encoded with gob, should implement it
+type Cloner interface {
+	Runner // it's a Runner
+
+	// Clone returns a deep copy of the Runner, including the Runners that it
+	// wraps (see Clone), that can be executed independently of the original
+	Clone() Runner
+}
+
+// Clone returns a deep copy of the runner tree that can be executed
+// independently of, and concurrently with, the original runner. It allows
+// reusing a planned runner instead of re-planning it. Built-in runners are
+// copied without their per-run state, where exchanges also get new UIDs, as
+// exchanges with the same UID can't run at the same time, and distributed
+// runners get new execution IDs. Other runners are copied by their Clone if
+// they implement Cloner, or otherwise by encoding and decoding them with gob,
+// like they're encoded when distributed to peers. It panics if the runner
+// can't be encoded
+func Clone(r Runner) Runner {
+	if kind, children := Decompose(r); kind != NotComposite {
+		cloned := make([]Runner, len(children))
+		for i, child := range children {
+			cloned[i] = Clone(child)
+		}
+
+		res := Recompose(r, cloned)
+		if dist, ok := res.(*distRunner); ok {
+			dist.ExecID = newExecID() // tracked apart from the original, see Shutdown
+		}
+		return res
+	}
+
+	switch r := r.(type) {
+	case *exchange:
+		uid, _ := uuid.NewV4()
+		return &exchange{
+			UID:           uid.String(),
+			Type:          r.Type,
+			SortingCols:   append([]SortingCol(nil), r.SortingCols...),
+			PartitionCols: append([]int(nil), r.PartitionCols...),
+		}
+	case *compose:
+		res := *r
+		res.Scps = cloneScopes(r.Scps)
+		res.Cmps = make([]Composable, len(r.Cmps))
+		for i, cmp := range r.Cmps {
+			res.Cmps[i] = cloneComposable(cmp)
+		}
+		return &res
+	case *rows:
+		pending := make([]Runner, len(r.Pending))
+		for i, p := range r.Pending {
+			pending[i] = Clone(p)
+		}
+		return &rows{Runner: Clone(r.Runner), Pending: pending, Ctx: r.BaseCtx, BaseCtx: r.BaseCtx}
+	case *pick:
+		return &pick{append([]int(nil), r.Indices...)}
+	case *tail:
+		return &tail{r.Types}
+	case *passThrough:
+		if r == passThroughSingleton {
+			return r // filtered by identity, see Pipeline
+		}
+		return &passThrough{append([]Type(nil), r.ReturnTypes...), cloneScopes(r.scopes)}
+	case *batch:
+		return &batch{r.Size}
+	case *constt:
+		return &constt{r.Data} // never modified
+	case *values:
+		return &values{r.Types, r.Data} // never modified
+	case *dummyRunner:
+		return dummyRunnerSingleton // compared by identity, see project
+	case Cloner:
+		return r.Clone()
+	}
+
+	res, err := gobClone(r)
+	if err != nil {
+		panic(fmt.Sprintf("ep: can't clone %s: %s", runnerName(r), err))
+	}
+	return res.(Runner)
+}
+
+// cloneScopes returns a copy of the scopes, or nil if they're nil
+func cloneScopes(scopes StringsSet) StringsSet {
+	if scopes == nil {
+		return nil
+	}
+
+	res := make(StringsSet, len(scopes))
+	res.AddAll(scopes)
+	return res
+}
+
+// cloneRunner is similar to Clone, except that it returns an error instead of
+// panicking when the runner can't be cloned
+func cloneRunner(r Runner) (res Runner, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+	return Clone(r), nil
+}
+
+func cloneComposable(cmp Composable) Composable {
+	switch cmp := cmp.(type) {
+	case composeProject:
+		res := make(composeProject, len(cmp))
+		for i, c := range cmp {
+			res[i] = cloneComposable(c)
+		}
+		return res
+	case Runner:
+		return Clone(cmp).(Composable)
+	}
+
+	res, err := gobClone(cmp)
+	if err != nil {
+		panic(fmt.Sprintf("ep: can't clone %T: %s", cmp, err))
+	}
+	return res.(Composable)
+}
+
+// cloned wraps a value in order to encode it as an interface
+type cloned struct{ Value interface{} }
+
+// gobClone copies the value by encoding and decoding it
+func gobClone(v interface{}) (interface{}, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&cloned{v})
+	if err != nil {
+		return nil, err
+	}
+
+	var res cloned
+	err = gob.NewDecoder(&buf).Decode(&res)
+	return res.Value, err
+}
@@ -0,0 +1,21 @@
+package ep
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClone_singletons(t *testing.T) {
+	require.True(t, Clone(passThroughSingleton) == passThroughSingleton)
+	require.True(t, Clone(dummyRunnerSingleton) == dummyRunnerSingleton)
+
+	r := PassThrough(str)
+	require.False(t, Clone(r) == r)
+}
+
+func TestClone_distRunner(t *testing.T) {
+	r := &distRunner{Runner: PassThrough(), ExecID: newExecID()}
+	cloned := Clone(r).(*distRunner)
+	require.NotEqual(t, r.ExecID, cloned.ExecID)
+	require.NotEmpty(t, cloned.ExecID)
+}
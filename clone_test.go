@@ -0,0 +1,98 @@
+package ep_test
+
+import (
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+// counter counts its input rows, and clones itself without its count
+type counter struct {
+	Rows int
+}
+
+func (*counter) Equals(other interface{}) bool { _, ok := other.(*counter); return ok }
+func (*counter) Returns() []ep.Type            { return []ep.Type{ep.Wildcard} }
+func (*counter) Clone() ep.Runner              { return &counter{} }
+func (r *counter) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		r.Rows += data.Len()
+		out <- data
+	}
+	return nil
+}
+
+func TestClone(t *testing.T) {
+	r := ep.Pipeline(
+		ep.Scatter(),
+		ep.Project(ep.Alias(&upper{}, "up"), ep.Pick(0)),
+		ep.Broadcast(),
+		ep.Gather(),
+	)
+
+	cloned := ep.Clone(r)
+	require.True(t, r.Equals(cloned))
+	require.Equal(t, ep.Explain(r), ep.Explain(cloned))
+
+	// exchanges have new UIDs
+	require.NotEqual(t, ep.Spew(r), ep.Spew(cloned))
+}
+
+func TestClone_cloner(t *testing.T) {
+	r := &counter{Rows: 10}
+	cloned := ep.Clone(ep.Pipeline(r, &upper{}))
+
+	_, children := ep.Decompose(cloned)
+	require.Equal(t, 0, children[0].(*counter).Rows)
+	require.Equal(t, 10, r.Rows)
+}
+
+func TestClone_passThrough(t *testing.T) {
+	scopes := ep.StringsSet{"a": struct{}{}}
+	types := []ep.Type{str}
+	r := ep.PassThroughWithScopes(scopes, types...)
+	cloned := ep.Clone(r)
+
+	// the clone doesn't share the scopes and types of the original
+	scopes["b"] = struct{}{}
+	r.Returns()[0] = integer
+	require.Equal(t, ep.StringsSet{"a": struct{}{}}, cloned.(ep.ScopesRunner).Scopes())
+	require.Equal(t, []ep.Type{str}, cloned.Returns())
+}
+
+func TestClone_notEncodable(t *testing.T) {
+	// weighted isn't registered with gob
+	require.Panics(t, func() { ep.Clone(ep.Pipeline(&upper{}, &weighted{1})) })
+}
+
+func TestClone_concurrentRuns(t *testing.T) {
+	dist1 := eptest.NewPeer(t, ":5551")
+	dist2 := eptest.NewPeer(t, ":5552")
+	defer eptest.ClosePeer(t, dist1)
+	defer eptest.ClosePeer(t, dist2)
+
+	r := dist1.Distribute(ep.Pipeline(ep.Scatter(), &upper{}, ep.Gather()), ":5551", ":5552")
+
+	runners := []ep.Runner{r, ep.Clone(r), ep.Clone(r)}
+	results := make([]ep.Dataset, len(runners))
+	errs := make([]error, len(runners))
+
+	var wg sync.WaitGroup
+	for i, runner := range runners {
+		wg.Add(1)
+		go func(i int, runner ep.Runner) {
+			defer wg.Done()
+			data := ep.NewDataset(strs{"hello", "world"})
+			results[i], errs[i] = eptest.Run(runner, data)
+		}(i, runner)
+	}
+	wg.Wait()
+
+	for i := range runners {
+		require.NoError(t, errs[i])
+		require.ElementsMatch(t, []string{"HELLO", "WORLD"}, results[i].At(0).Strings())
+	}
+}
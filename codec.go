@@ -0,0 +1,120 @@
+package ep
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var _ = registerGob(&encodedDataset{})
+
+// Codec encodes the datasets that exchanges send between nodes, instead of
+// encoding them with gob. Codecs are registered by their names in the Codecs
+// registry of every node, and are picked by the context of the executed runner,
+// see WithCodec. Decoded datasets should hold the same values as the encoded
+// ones, but their types may differ, as long as the receiving runners accept them
+type Codec interface {
+	// Encode returns the binary representation of the dataset
+	Encode(data Dataset) ([]byte, error)
+
+	// Decode returns the dataset of its binary representation, as returned by
+	// Encode
+	Decode(b []byte) (Dataset, error)
+}
+
+// Codecs registry. Maps names to the codecs that can be picked by WithCodec.
+// Codecs must be registered on all nodes, as the datasets are decoded by the
+// codec of the name that they're sent with:
+//
+//      ep.Codecs.Register("arrow", codec)
+var Codecs = &codecsReg{codecs: make(map[string]Codec)}
+
+// registry of codecs. It's safe for concurrent use
+type codecsReg struct {
+	l      sync.RWMutex
+	codecs map[string]Codec
+}
+
+// Register a name-codec pair to be globally accessible via the Get() function
+// using the same name.
+func (reg *codecsReg) Register(name string, c Codec) *codecsReg {
+	reg.l.Lock()
+	defer reg.l.Unlock()
+	reg.codecs[name] = c
+	return reg
+}
+
+// Get the codec that was previously registered to the provided name via the
+// Register() function, or nil if that name is unknown.
+func (reg *codecsReg) Get(name string) Codec {
+	reg.l.RLock()
+	defer reg.l.RUnlock()
+	return reg.codecs[name]
+}
+
+// WithCodec returns a new context that encodes the datasets sent by exchanges
+// with the codec registered to the provided name, instead of gob. Distributed
+// runners pass the codec over to their peers, so the datasets are encoded by
+// the same codec on all nodes. Datasets exchanged within the same node aren't
+// encoded at all
+func WithCodec(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, codecKey, name)
+}
+
+// codecName returns the name of the codec of the context, or an empty string
+// for gob, see WithCodec
+func codecName(ctx context.Context) string {
+	name, _ := ctx.Value(codecKey).(string)
+	return name
+}
+
+// encodedDataset is a dataset encoded by the codec of its name
+type encodedDataset struct {
+	Codec string
+	Bytes []byte
+}
+
+// codecEncoder encodes the datasets of the requests with the codec of its
+// name, before they're encoded by the underlying encoder
+type codecEncoder struct {
+	encoder
+	name string
+}
+
+func (enc *codecEncoder) Encode(e interface{}) error {
+	r, ok := e.(*req)
+	if !ok {
+		return enc.encoder.Encode(e)
+	}
+
+	data, ok := r.Payload.(Dataset)
+	if !ok {
+		return enc.encoder.Encode(e)
+	}
+
+	codec := Codecs.Get(enc.name)
+	if codec == nil {
+		return fmt.Errorf("ep: unknown codec %q", enc.name)
+	}
+
+	b, err := codec.Encode(data)
+	if err != nil {
+		return err
+	}
+	return enc.encoder.Encode(&req{&encodedDataset{enc.name, b}})
+}
+
+// decodeDataset returns the dataset of the decoded payload, which is decoded
+// by its codec if it was encoded by one, see codecEncoder
+func decodeDataset(payload interface{}) (Dataset, error) {
+	encoded, ok := payload.(*encodedDataset)
+	if !ok {
+		return payload.(Dataset), nil
+	}
+
+	codec := Codecs.Get(encoded.Codec)
+	if codec == nil {
+		return nil, fmt.Errorf("ep: unknown codec %q", encoded.Codec)
+	}
+	return codec.Decode(encoded.Bytes)
+}
@@ -0,0 +1,81 @@
+package ep
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+)
+
+var _ = Codecs.Register("counting", &countingCodec{})
+
+// countingCodec encodes datasets with gob, and counts them
+type countingCodec struct{ encoded, decoded int64 }
+
+func (c *countingCodec) Encode(data Dataset) ([]byte, error) {
+	atomic.AddInt64(&c.encoded, 1)
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&req{data})
+	return buf.Bytes(), err
+}
+
+func (c *countingCodec) Decode(b []byte) (Dataset, error) {
+	atomic.AddInt64(&c.decoded, 1)
+	var res req
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Payload.(Dataset), nil
+}
+
+func TestWithCodec(t *testing.T) {
+	dists := startCluster(t, ":5551", ":5552")
+	defer terminateCluster(t, dists...)
+
+	codec := Codecs.Get("counting").(*countingCodec)
+	atomic.StoreInt64(&codec.encoded, 0)
+	atomic.StoreInt64(&codec.decoded, 0)
+
+	runner := dists[0].Distribute(Pipeline(Scatter(), Gather()), ":5551", ":5552")
+	inp := make(chan Dataset, 2)
+	inp <- NewDataset(strs{"a"})
+	inp <- NewDataset(strs{"b"})
+	close(inp)
+
+	var err error
+	var res []string
+	out := make(chan Dataset)
+	go Run(WithCodec(context.Background(), "counting"), runner, inp, out, nil, &err)
+	for data := range out {
+		res = append(res, data.At(0).Strings()...)
+	}
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b"}, res)
+
+	// scattered from the master to the peer, and gathered back by the peer,
+	// while datasets that remain on the same node aren't encoded
+	require.Equal(t, int64(2), atomic.LoadInt64(&codec.encoded))
+	require.Equal(t, int64(2), atomic.LoadInt64(&codec.decoded))
+}
+
+func TestWithCodec_unknown(t *testing.T) {
+	dists := startCluster(t, ":5551", ":5552")
+	defer terminateCluster(t, dists...)
+
+	runner := dists[0].Distribute(Pipeline(Scatter(), Gather()), ":5551", ":5552")
+	inp := make(chan Dataset, 2)
+	inp <- NewDataset(strs{"a"})
+	inp <- NewDataset(strs{"b"})
+	close(inp)
+
+	var err error
+	out := make(chan Dataset)
+	go Run(WithCodec(context.Background(), "unknown"), runner, inp, out, nil, &err)
+	for range out {
+	}
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `ep: unknown codec "unknown"`)
+}
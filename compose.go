@@ -99,6 +99,7 @@ func (c *compose) BatchFunction() BatchFunction {
 }
 
 func (c *compose) Scopes() StringsSet   { return c.Scps }
+func (c *compose) Idempotent() bool     { return areIdempotentComposables(c.Cmps) }
 func (c *compose) SetAlias(name string) { c.Alias = name }
 
 func (c *compose) Filter(keep []bool) {
@@ -173,6 +174,20 @@ func (cs composeProject) BatchFunction() BatchFunction {
 	}
 }
 
+func (cs composeProject) Idempotent() bool { return areIdempotentComposables(cs) }
+
+// areIdempotentComposables returns true if all of the given composables are
+// idempotent, see IdempotentRunner
+func areIdempotentComposables(cmps []Composable) bool {
+	for _, c := range cmps {
+		ic, ok := c.(interface{ Idempotent() bool })
+		if !ok || !ic.Idempotent() {
+			return false
+		}
+	}
+	return true
+}
+
 func (cs composeProject) Filter(keep []bool) {
 	currIdx := 0
 	for i, c := range cs {
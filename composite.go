@@ -0,0 +1,103 @@
+package ep
+
+import (
+	"fmt"
+)
+
+// CompositeKind identifies the built-in composite runners, see Decompose
+type CompositeKind int
+
+const (
+	// NotComposite is the kind of all runners that aren't composed of other
+	// runners, including Compose, which is composed of Composables
+	NotComposite CompositeKind = iota
+
+	// PipelineComposite is the kind of runners returned by Pipeline
+	PipelineComposite
+
+	// ProjectComposite is the kind of runners returned by Project
+	ProjectComposite
+
+	// UnionComposite is the kind of runners returned by Union
+	UnionComposite
+
+	// AliasComposite is the kind of runners returned by Alias
+	AliasComposite
+
+	// ScopeComposite is the kind of runners returned by Scope
+	ScopeComposite
+
+	// DistributedComposite is the kind of runners returned by
+	// Distributer.Distribute
+	DistributedComposite
+)
+
+// Decompose returns the kind of the composite runner and its inner runners,
+// in order to allow rewriting composed runners along with Recompose. Runners
+// that aren't composite return NotComposite and no inner runners
+func Decompose(r Runner) (CompositeKind, []Runner) {
+	var kind CompositeKind
+	switch r.(type) {
+	case pipeline:
+		kind = PipelineComposite
+	case project:
+		kind = ProjectComposite
+	case union:
+		kind = UnionComposite
+	case *alias:
+		kind = AliasComposite
+	case *scope:
+		kind = ScopeComposite
+	case *distRunner:
+		kind = DistributedComposite
+	default:
+		return NotComposite, nil
+	}
+
+	children := runnerChildren(r)
+	res := make([]Runner, len(children))
+	for i, child := range children {
+		res[i] = child.runner
+	}
+	return kind, res
+}
+
+// Recompose returns a new composite runner of the same kind and configuration
+// as r, composed of the provided inner runners instead of its own. Unlike the
+// constructors of the composite runners (Pipeline, Project, etc.), the inner
+// runners are used as-is, without flattening or merging them. It panics if r
+// isn't composite, or if the number of inner runners doesn't fit its kind
+func Recompose(r Runner, children []Runner) Runner {
+	kind, _ := Decompose(r)
+	if kind == NotComposite {
+		panic(fmt.Sprintf("ep: %s isn't a composite runner", runnerName(r)))
+	}
+	if len(children) == 0 {
+		panic("ep: at least 1 runner is required for composite runners")
+	}
+
+	switch r.(type) {
+	case pipeline:
+		return pipeline(children)
+	case project:
+		return project(children)
+	case union:
+		return union(children)
+	}
+
+	if len(children) != 1 {
+		panic(fmt.Sprintf("ep: %s wraps a single runner", runnerName(r)))
+	}
+
+	switch r := r.(type) {
+	case *alias:
+		return &alias{children[0], r.Label}
+	case *scope:
+		return &scope{children[0], r.Label}
+	case *distRunner:
+		res := *r
+		res.Runner = children[0]
+		return &res
+	}
+	return nil // unreachable, see Decompose
+}
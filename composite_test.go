@@ -0,0 +1,57 @@
+package ep_test
+
+import (
+	"github.com/panoplyio/ep"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecompose(t *testing.T) {
+	a, b := &upper{}, &question{}
+	union, err := ep.Union(a, b)
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name     string
+		runner   ep.Runner
+		kind     ep.CompositeKind
+		children []ep.Runner
+	}{
+		{"pipeline", ep.Pipeline(a, b), ep.PipelineComposite, []ep.Runner{a, b}},
+		{"project", ep.Project(a, b), ep.ProjectComposite, []ep.Runner{a, b}},
+		{"union", union, ep.UnionComposite, []ep.Runner{a, b}},
+		{"alias", ep.Alias(a, "x"), ep.AliasComposite, []ep.Runner{a}},
+		{"scope", ep.Scope(a, "x"), ep.ScopeComposite, []ep.Runner{a}},
+		{"leaf", a, ep.NotComposite, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			kind, children := ep.Decompose(tc.runner)
+			require.Equal(t, tc.kind, kind)
+			require.Equal(t, tc.children, children)
+
+			if kind == ep.NotComposite {
+				require.Panics(t, func() { ep.Recompose(tc.runner, []ep.Runner{a}) })
+				return
+			}
+
+			replaced := make([]ep.Runner, len(children))
+			for i := range replaced {
+				replaced[i] = &count{}
+			}
+			res := ep.Recompose(tc.runner, replaced)
+			kind, children = ep.Decompose(res)
+			require.Equal(t, tc.kind, kind)
+			require.Equal(t, replaced, children)
+		})
+	}
+}
+
+func TestRecompose_keepsConfiguration(t *testing.T) {
+	res := ep.Recompose(ep.Alias(&upper{}, "x"), []ep.Runner{&question{}})
+	require.Equal(t, "x", ep.GetAlias(res.Returns()[0]))
+
+	require.Panics(t, func() { ep.Recompose(ep.Alias(&upper{}, "x"), []ep.Runner{&upper{}, &upper{}}) })
+	require.Panics(t, func() { ep.Recompose(ep.Pipeline(&upper{}, &upper{}), nil) })
+}
@@ -29,6 +29,7 @@ func (r *constt) Equals(other interface{}) bool {
 	return err == nil && c[0] == compare.Equal
 }
 func (r *constt) Returns() []Type { return []Type{r.Data.Type()} }
+func (*constt) Idempotent() bool  { return true }
 func (r *constt) Run(_ context.Context, inp, out chan Dataset) error {
 	for data := range inp {
 		res, _ := r.run(data)
@@ -0,0 +1,209 @@
+package ep
+
+import (
+	"fmt"
+)
+
+// SelectivityRunner is a Runner that also estimates the fraction of its input
+// rows that it produces, like filters. Used by CostModel along with
+// ApproxSizer, which estimates the number of rows produced by data sources
+type SelectivityRunner interface {
+	Runner // it's a Runner
+
+	// Selectivity returns the estimated ratio between the number of output
+	// rows and input rows
+	Selectivity() float64
+}
+
+// Estimate is the estimated output and cost of a Runner, see CostModel
+type Estimate struct {
+	Rows  int     // number of output rows, across all nodes
+	Cost  float64 // total cost, including the cost of producing the input
+	Known bool    // false when not based on any size estimation
+}
+
+// CostModel estimates the cost of running Runners, based on the estimated
+// number of rows processed by each Runner (see ApproxSizer and
+// SelectivityRunner) and the number of rows transferred between nodes by
+// exchanges. Planners use it to choose between alternative plans
+type CostModel struct {
+	RowCost         float64 // cost of processing a single row
+	NetworkCost     float64 // cost of transferring a single row between nodes
+	DefaultRows     int     // number of rows assumed when it's unknown
+	JoinSelectivity float64 // ratio of the cross product produced by joins
+}
+
+// DefaultCostModel is the CostModel used by Explain
+var DefaultCostModel = CostModel{
+	RowCost:         1,
+	NetworkCost:     10,
+	DefaultRows:     1000,
+	JoinSelectivity: 0.1,
+}
+
+// Estimate returns the estimated output and cost of running r
+func (m CostModel) Estimate(r Runner) Estimate {
+	return m.estimate(r, Estimate{Rows: UnknownSize}, 1, "", nil)
+}
+
+// estimate the runner for the provided input estimate, when running on the
+// given number of nodes. The input of the top-level runner is UnknownSize.
+// visit is called with the estimate of every runner in the tree, along with
+// its path (see Stats)
+func (m CostModel) estimate(r Runner, inp Estimate, nodes int, path string, visit func(string, Estimate)) (res Estimate) {
+	if visit != nil {
+		defer func() { visit(path, res) }()
+	}
+	at := func(step string) string {
+		if path == "" {
+			return step
+		}
+		return path + "/" + step
+	}
+
+	switch r := r.(type) {
+	case pipeline:
+		res = inp
+		for i, child := range r {
+			res = m.estimate(child, res, nodes, at(fmt.Sprintf("pipeline[%d]", i)), visit)
+		}
+		return res
+
+	case project, union:
+		kind, children := Decompose(r)
+		res = Estimate{Cost: inp.Cost}
+		for i, child := range children {
+			step := fmt.Sprintf("project[%d]", i)
+			if kind == UnionComposite {
+				step = fmt.Sprintf("union[%d]", i)
+			}
+
+			childRes := m.estimate(child, inp, nodes, at(step), visit)
+			res.Cost += childRes.Cost - inp.Cost
+			res.Known = res.Known || childRes.Known
+			if kind == UnionComposite {
+				res.Rows += childRes.Rows
+			} else if childRes.Rows > res.Rows {
+				res.Rows = childRes.Rows
+			}
+		}
+		return res
+
+	case *alias:
+		return m.estimate(r.Runner, inp, nodes, path, visit)
+
+	case *scope:
+		return m.estimate(r.Runner, inp, nodes, path, visit)
+
+	case *distRunner:
+		return m.estimate(r.Runner, inp, len(r.Addrs), at("distribute"), visit)
+	}
+
+	res = inp
+	if sizer, ok := r.(ApproxSizer); ok && sizer.ApproxSize() != UnknownSize {
+		res = Estimate{Rows: sizer.ApproxSize(), Known: true}
+	} else {
+		if inp.Rows == UnknownSize {
+			res.Rows = m.DefaultRows
+		}
+		if selective, ok := r.(SelectivityRunner); ok {
+			res.Rows = int(float64(res.Rows) * selective.Selectivity())
+		}
+	}
+
+	processed := res.Rows
+	if inp.Rows > processed {
+		processed = inp.Rows
+	}
+	res.Cost = inp.Cost + m.RowCost*float64(processed)
+
+	if ex, ok := r.(*exchange); ok && nodes > 1 {
+		// rows that are transferred to other nodes, assuming uniform
+		// distribution of the input across all nodes
+		transferred := float64(res.Rows) * float64(nodes-1) / float64(nodes)
+		if ex.Type == broadcast {
+			transferred = float64(res.Rows) * float64(nodes-1)
+			res.Rows *= nodes
+		}
+		res.Cost += m.NetworkCost * transferred
+	}
+	return res
+}
+
+// JoinDistribution is the way of distributing the two sides of a join, such
+// that matching rows end up on the same node
+type JoinDistribution int
+
+const (
+	// PartitionJoin partitions both sides by the join columns, see Partition
+	PartitionJoin JoinDistribution = iota
+
+	// BroadcastLeft broadcasts the left side to all nodes, see Broadcast
+	BroadcastLeft
+
+	// BroadcastRight broadcasts the right side to all nodes, see Broadcast
+	BroadcastRight
+)
+
+// JoinDistribution returns the cheapest way to distribute the two sides of a
+// join across the given number of nodes. Broadcasting a side transfers all of
+// its rows to all other nodes, while partitioning transfers most rows of both
+// sides once
+func (m CostModel) JoinDistribution(left, right Runner, nodes int) JoinDistribution {
+	leftRows := float64(m.Estimate(left).Rows)
+	rightRows := float64(m.Estimate(right).Rows)
+	others := float64(nodes - 1)
+
+	res := PartitionJoin
+	cost := (leftRows + rightRows) * others / float64(nodes)
+	if broadcastCost := leftRows * others; broadcastCost < cost {
+		res, cost = BroadcastLeft, broadcastCost
+	}
+	if broadcastCost := rightRows * others; broadcastCost < cost {
+		res = BroadcastRight
+	}
+	return res
+}
+
+// JoinOrder returns the order, as indices of the provided inputs, in which a
+// multi-way join should join them. Inputs are greedily added in the order
+// that minimizes the estimated size of the intermediate results, starting
+// with the smallest input. selectivity estimates the ratio of the cross
+// product produced by joining the next input with the already joined ones.
+// When it's nil, JoinSelectivity is used
+func (m CostModel) JoinOrder(inputs []Runner, selectivity func(joined []int, next int) float64) []int {
+	if selectivity == nil {
+		selectivity = func([]int, int) float64 { return m.JoinSelectivity }
+	}
+
+	rows := make([]float64, len(inputs))
+	for i, r := range inputs {
+		rows[i] = float64(m.Estimate(r).Rows)
+	}
+
+	var order []int
+	var joinedRows float64
+	used := make([]bool, len(inputs))
+	for len(order) < len(inputs) {
+		next := -1
+		var nextRows float64
+		for i := range inputs {
+			if used[i] {
+				continue
+			}
+
+			res := rows[i]
+			if len(order) > 0 {
+				res = joinedRows * rows[i] * selectivity(order, i)
+			}
+			if next == -1 || res < nextRows {
+				next, nextRows = i, res
+			}
+		}
+
+		used[next] = true
+		order = append(order, next)
+		joinedRows = nextRows
+	}
+	return order
+}
@@ -0,0 +1,94 @@
+package ep_test
+
+import (
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// sized is a data source with a known size
+type sized struct{ Size int }
+
+func (*sized) Equals(other interface{}) bool { return false }
+func (*sized) Returns() []ep.Type            { return []ep.Type{str} }
+func (r *sized) ApproxSize() int             { return r.Size }
+func (*sized) Run(context.Context, chan ep.Dataset, chan ep.Dataset) error {
+	return nil
+}
+
+// selective is a filter with a known selectivity
+type selective struct{ Ratio float64 }
+
+func (*selective) Equals(other interface{}) bool { return false }
+func (*selective) Returns() []ep.Type            { return []ep.Type{ep.Wildcard} }
+func (r *selective) Selectivity() float64        { return r.Ratio }
+func (*selective) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		out <- data
+	}
+	return nil
+}
+
+func TestCostModel_Estimate(t *testing.T) {
+	m := ep.DefaultCostModel
+
+	res := m.Estimate(ep.Pipeline(&sized{100}, &selective{0.5}, &upper{}))
+	require.Equal(t, ep.Estimate{Rows: 50, Cost: 100 + 100 + 50, Known: true}, res)
+
+	res = m.Estimate(&upper{})
+	require.Equal(t, ep.Estimate{Rows: m.DefaultRows, Cost: float64(m.DefaultRows)}, res)
+
+	union, err := ep.Union(&sized{10}, &sized{20})
+	require.NoError(t, err)
+	res = m.Estimate(union)
+	require.Equal(t, ep.Estimate{Rows: 30, Cost: 30, Known: true}, res)
+}
+
+func TestCostModel_Estimate_exchanges(t *testing.T) {
+	dist := eptest.NewPeer(t, ":5551")
+	defer eptest.ClosePeer(t, dist)
+
+	m := ep.DefaultCostModel
+
+	gather := m.Estimate(dist.Distribute(ep.Pipeline(&sized{100}, ep.Gather()), ":5551", ":5552"))
+	require.Equal(t, 100, gather.Rows)
+	require.Equal(t, 100+100+m.NetworkCost*50, gather.Cost)
+
+	broadcast := m.Estimate(dist.Distribute(ep.Pipeline(&sized{100}, ep.Broadcast()), ":5551", ":5552"))
+	require.Equal(t, 200, broadcast.Rows)
+	require.Equal(t, 100+100+m.NetworkCost*100, broadcast.Cost)
+}
+
+func TestCostModel_JoinDistribution(t *testing.T) {
+	m := ep.DefaultCostModel
+	small, large := &sized{10}, &sized{100000}
+
+	require.Equal(t, ep.BroadcastLeft, m.JoinDistribution(small, large, 4))
+	require.Equal(t, ep.BroadcastRight, m.JoinDistribution(large, small, 4))
+	require.Equal(t, ep.PartitionJoin, m.JoinDistribution(large, &sized{90000}, 4))
+}
+
+func TestCostModel_JoinOrder(t *testing.T) {
+	m := ep.DefaultCostModel
+	inputs := []ep.Runner{&sized{1000}, &sized{10}, &sized{100}}
+	require.Equal(t, []int{1, 2, 0}, m.JoinOrder(inputs, nil))
+
+	// joining the largest input with the smallest one is very selective
+	selectivity := func(joined []int, next int) float64 {
+		if next == 0 {
+			return 0.0001
+		}
+		return 0.5
+	}
+	require.Equal(t, []int{1, 0, 2}, m.JoinOrder(inputs, selectivity))
+}
+
+func TestExplain_cost(t *testing.T) {
+	lines := strings.Split(ep.Explain(ep.Pipeline(&sized{100}, &selective{0.5})), "\n")
+	require.Equal(t, "pipeline [string] size=100 rows=50 cost=200", lines[0])
+	require.Equal(t, "  ep_test.sized [string] size=100 rows=100 cost=100", lines[1])
+	require.Equal(t, "  ep_test.selective [*] rows=50 cost=200", lines[2])
+}
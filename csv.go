@@ -0,0 +1,228 @@
+package ep
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// DefaultCSVBatchSize is the number of rows in each of the datasets produced
+// by ReadCSV, unless it's configured by its CSVOptions
+const DefaultCSVBatchSize = 1000
+
+// StringsParser is a Type whose values can also be parsed from their string
+// representation, which is the reverse of Data.Strings(). It's required by
+// ReadCSV for all of its types
+type StringsParser interface {
+	Type // it's a Type
+
+	// ParseStrings returns a new Data with the values parsed from the strings,
+	// where the rows that are marked in nulls are nulls, and their strings
+	// should be ignored
+	ParseStrings(values []string, nulls []bool) (Data, error)
+}
+
+// CSVOptions configures ReadCSV and WriteCSVWithOptions
+type CSVOptions struct {
+	// Comma is the field delimiter, defaults to ','
+	Comma rune
+
+	// Header declares that the first record holds the names of the columns,
+	// which are set as the aliases of the types, see SetAlias. It's ignored
+	// by WriteCSVWithOptions, as its input isn't named
+	Header bool
+
+	// NullString is the value of null fields, defaults to empty fields. It
+	// should differ from all of the non-null values in order to keep nulls
+	// apart from empty strings
+	NullString string
+
+	// BatchSize is the number of rows in each dataset read by ReadCSV,
+	// defaults to DefaultCSVBatchSize
+	BatchSize int
+}
+
+// ReadCSV returns a new producing Runner that reads the CSV records from the
+// reader, and emits them in batches of the provided types, parsed by their
+// StringsParser. The header, if any, is read immediately, in order to declare
+// the returned types without reading from the reader while planning, and its
+// errors are returned by Run. Records are consumed from the reader as they're
+// emitted, so running it again only emits the records left by the previous
+// run. It's not registered with gob, as the reader can't be sent to other
+// nodes, so distributing it fails to encode it
+func ReadCSV(r io.Reader, types []Type, opts CSVOptions) Runner {
+	if len(types) == 0 {
+		panic("at least 1 type is required for csv")
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(types)
+	reader.ReuseRecord = true
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultCSVBatchSize
+	}
+
+	res := &csvReader{Reader: reader, Types: types, Opts: opts}
+	if opts.Header {
+		res.readHeader()
+	}
+	return res
+}
+
+type csvReader struct {
+	Reader *csv.Reader
+	Types  []Type
+	Opts   CSVOptions
+
+	err error // of reading the header
+}
+
+func (r *csvReader) Equals(other interface{}) bool { return r == other }
+func (r *csvReader) Returns() []Type               { return r.Types }
+
+// readHeader sets the aliases of the types to the names of the header
+func (r *csvReader) readHeader() {
+	header, err := r.Reader.Read()
+	if err == io.EOF {
+		return // empty, without a header
+	} else if err != nil {
+		r.err = err
+		return
+	}
+
+	types := make([]Type, len(r.Types))
+	for i, t := range r.Types {
+		types[i] = SetAlias(t, header[i])
+	}
+	r.Types = types
+}
+
+func (r *csvReader) Run(ctx context.Context, inp, out chan Dataset) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	parsers := make([]StringsParser, len(r.Types))
+	for i, t := range r.Types {
+		parser, ok := UnderlyingType(t).(StringsParser)
+		if !ok {
+			return fmt.Errorf("ep: %s doesn't implement StringsParser", t)
+		}
+		parsers[i] = parser
+	}
+
+	for {
+		cols, err := r.read()
+		if len(cols[0]) > 0 {
+			data, parseErr := r.parse(parsers, cols)
+			if parseErr != nil {
+				return parseErr
+			}
+
+			select {
+			case out <- data:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// read the next batch of records, as the values of each column
+func (r *csvReader) read() ([][]string, error) {
+	cols := make([][]string, len(r.Types))
+	for i := range cols {
+		cols[i] = make([]string, 0, r.Opts.BatchSize)
+	}
+
+	for len(cols[0]) < r.Opts.BatchSize {
+		record, err := r.Reader.Read()
+		if err != nil {
+			return cols, err
+		}
+		for i, v := range record {
+			cols[i] = append(cols[i], v)
+		}
+	}
+	return cols, nil
+}
+
+func (r *csvReader) parse(parsers []StringsParser, cols [][]string) (Dataset, error) {
+	res := make([]Data, len(cols))
+	for i, values := range cols {
+		nulls := make([]bool, len(values))
+		for row, v := range values {
+			nulls[row] = v == r.Opts.NullString
+		}
+
+		data, err := parsers[i].ParseStrings(values, nulls)
+		if err != nil {
+			return nil, fmt.Errorf("ep: can't parse CSV column %d: %s", i, err)
+		}
+		res[i] = data
+	}
+	return NewDataset(res...), nil
+}
+
+// WriteCSV returns a new terminal Runner that writes its input to the writer
+// as CSV records of the Strings() of its columns, where nulls are empty
+// fields. It doesn't produce any output, and the records are flushed to the
+// writer once its input is closed
+func WriteCSV(w io.Writer) Runner {
+	return WriteCSVWithOptions(w, CSVOptions{})
+}
+
+// WriteCSVWithOptions is similar to WriteCSV, except that nulls are written as
+// the NullString of the options, so they're read back as nulls by ReadCSV with
+// the same options, rather than as empty strings
+func WriteCSVWithOptions(w io.Writer, opts CSVOptions) Runner {
+	writer := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		writer.Comma = opts.Comma
+	}
+	return &csvWriter{writer, opts}
+}
+
+type csvWriter struct {
+	Writer *csv.Writer
+	Opts   CSVOptions
+}
+
+func (r *csvWriter) Equals(other interface{}) bool { return r == other }
+func (*csvWriter) Returns() []Type                 { return []Type{} }
+func (r *csvWriter) Run(ctx context.Context, inp, out chan Dataset) error {
+	for data := range inp {
+		cols := make([][]string, data.Width())
+		for i := range cols {
+			cols[i] = data.At(i).Strings()
+		}
+
+		record := make([]string, len(cols))
+		for row := 0; row < data.Len(); row++ {
+			for i, col := range cols {
+				record[i] = col[row]
+				if data.At(i).IsNull(row) {
+					record[i] = r.Opts.NullString
+				}
+			}
+
+			err := r.Writer.Write(record)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	r.Writer.Flush()
+	return r.Writer.Error()
+}
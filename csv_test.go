@@ -0,0 +1,172 @@
+package ep_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func (*strType) ParseStrings(values []string, nulls []bool) (ep.Data, error) {
+	res := make(strs, len(values))
+	for i, v := range values {
+		if !nulls[i] {
+			res[i] = v // nulls are empty
+		}
+	}
+	return res, nil
+}
+
+func (*integerType) ParseStrings(values []string, nulls []bool) (ep.Data, error) {
+	res := make(integers, len(values))
+	for i, v := range values {
+		if nulls[i] {
+			continue // integers aren't nullable
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = n
+	}
+	return res, nil
+}
+
+func TestReadCSV(t *testing.T) {
+	input := "name,age\nalice,30\nbob,\ncarol,50\n"
+	runner := ep.ReadCSV(strings.NewReader(input), []ep.Type{str, integer}, ep.CSVOptions{Header: true})
+
+	types := runner.Returns()
+	require.Equal(t, "name", ep.GetAlias(types[0]))
+	require.Equal(t, "age", ep.GetAlias(types[1]))
+
+	data, err := eptest.Run(runner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"(alice,30)", "(bob,0)", "(carol,50)"}, data.Strings())
+
+	// batches
+	runner = ep.ReadCSV(strings.NewReader(input), []ep.Type{str, integer}, ep.CSVOptions{Header: true, BatchSize: 2})
+	data, err = eptest.Run(ep.Pipeline(runner, &count{}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"(2)", "(1)"}, data.Strings())
+}
+
+func TestReadCSV_options(t *testing.T) {
+	r := strings.NewReader("alice;30\nNULL;40\n")
+	opts := ep.CSVOptions{Comma: ';', NullString: "NULL"}
+	data, err := eptest.Run(ep.ReadCSV(r, []ep.Type{str, integer}, opts))
+	require.NoError(t, err)
+	require.Equal(t, []string{"(alice,30)", "(,40)"}, data.Strings())
+	require.True(t, data.At(0).IsNull(1))
+}
+
+func TestReadCSV_modifiedTypes(t *testing.T) {
+	// the parsers of aliased and nullable types are their underlying ones
+	types := []ep.Type{ep.SetAlias(str, "name"), ep.SetNullable(integer, false)}
+	runner := ep.ReadCSV(strings.NewReader("alice,30\n"), types, ep.CSVOptions{})
+	data, err := eptest.Run(runner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"(alice,30)"}, data.Strings())
+
+	// the header is read once, when the runner is created
+	r := strings.NewReader("name,age\nalice,30\n")
+	runner = ep.ReadCSV(r, types, ep.CSVOptions{Header: true})
+	require.Equal(t, "name", ep.GetAlias(runner.Returns()[0]))
+	require.Equal(t, "age", ep.GetAlias(runner.Returns()[1]))
+	data, err = eptest.Run(runner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"(alice,30)"}, data.Strings())
+}
+
+func TestReadCSV_errors(t *testing.T) {
+	r := strings.NewReader("alice,thirty\n")
+	_, err := eptest.Run(ep.ReadCSV(r, []ep.Type{str, integer}, ep.CSVOptions{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "ep: can't parse CSV column 1: ")
+
+	r = strings.NewReader("alice,30,extra\n")
+	_, err = eptest.Run(ep.ReadCSV(r, []ep.Type{str, integer}, ep.CSVOptions{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "wrong number of fields")
+
+	// errors of reading the header are returned by Run
+	r = strings.NewReader("name,age,extra\n")
+	_, err = eptest.Run(ep.ReadCSV(r, []ep.Type{str, integer}, ep.CSVOptions{Header: true}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "wrong number of fields")
+
+	r = strings.NewReader("alice\n")
+	_, err = eptest.Run(ep.ReadCSV(r, []ep.Type{ep.Record}, ep.CSVOptions{}))
+	require.EqualError(t, err, "ep: record doesn't implement StringsParser")
+}
+
+func TestReadCSV_cancel(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		input.WriteString("a\n")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := ep.ReadCSV(strings.NewReader(input.String()), []ep.Type{str}, ep.CSVOptions{BatchSize: 1})
+
+	inp, out := make(chan ep.Dataset), make(chan ep.Dataset)
+	close(inp)
+	errs := make(chan error)
+	go func() { errs <- runner.Run(ctx, inp, out) }()
+
+	<-out // first batch
+	cancel()
+	require.NoError(t, <-errs)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	data := ep.NewDataset(strs{"alice", "", "carol, jr"}, integers{30, 40, 50})
+	res, err := eptest.Run(ep.WriteCSV(&buf), data)
+	require.NoError(t, err)
+	require.Nil(t, res)
+	require.Equal(t, "alice,30\n,40\n\"carol, jr\",50\n", buf.String())
+
+	// round trip
+	r := ep.ReadCSV(&buf, []ep.Type{str, integer}, ep.CSVOptions{})
+	res, err = eptest.Run(r)
+	require.NoError(t, err)
+	require.Equal(t, data.Strings(), res.Strings())
+}
+
+// nullableStrs are strs that keep nulls apart from empty strings
+type nullableStrs struct {
+	strs
+	nulls []bool
+}
+
+func (vs nullableStrs) IsNull(i int) bool { return vs.nulls[i] }
+
+var nullableStr = &nullableStrType{}
+
+type nullableStrType struct{ strType }
+
+func (*nullableStrType) ParseStrings(values []string, nulls []bool) (ep.Data, error) {
+	return nullableStrs{append(strs(nil), values...), nulls}, nil
+}
+
+func TestWriteCSV_options(t *testing.T) {
+	var buf bytes.Buffer
+	opts := ep.CSVOptions{Comma: ';', NullString: `\N`}
+	data := ep.NewDataset(nullableStrs{strs{"alice", "", ""}, []bool{false, false, true}}, integers{30, 40, 50})
+	_, err := eptest.Run(ep.WriteCSVWithOptions(&buf, opts), data)
+	require.NoError(t, err)
+	require.Equal(t, "alice;30\n;40\n\\N;50\n", buf.String())
+
+	// round trip, where nulls remain apart from empty strings
+	var res bytes.Buffer
+	r := ep.ReadCSV(strings.NewReader(buf.String()), []ep.Type{nullableStr, integer}, opts)
+	_, err = eptest.Run(ep.Pipeline(r, ep.WriteCSVWithOptions(&res, opts)))
+	require.NoError(t, err)
+	require.Equal(t, buf.String(), res.String())
+}
@@ -3,10 +3,13 @@ package ep
 import (
 	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"github.com/satori/go.uuid"
 	"io"
 	"log"
 	"net"
+	"sort"
 	"sync"
 	"time"
 )
@@ -26,6 +29,29 @@ type Distributer interface {
 	// Stop listening for incoming Runners to run, and close all open
 	// connections.
 	Close() error
+
+	// Shutdown gracefully stops the Distributer. It stops accepting new Runners
+	// to run, waits for the in-flight Runners to complete and then closes the
+	// Distributer. If the context expires before they complete, the remaining
+	// Runners are canceled and reported via a ShutdownError, without waiting
+	// for them to return.
+	Shutdown(ctx context.Context) error
+}
+
+// ErrShutdown is returned by Runners that were either rejected or aborted by a
+// peer that is shutting down
+var ErrShutdown = errors.New("ep: distributer is shutting down")
+
+var _ = Errors.Register("shutdown", ErrShutdown)
+
+// ShutdownError is returned by Distributer.Shutdown when some of the in-flight
+// Runners didn't complete on time and had to be aborted
+type ShutdownError struct {
+	Aborted []string // sorted execution IDs of the aborted Runners
+}
+
+func (err *ShutdownError) Error() string {
+	return fmt.Sprintf("ep: shutdown aborted %d runners: %v", len(err.Aborted), err.Aborted)
 }
 
 type dialer interface {
@@ -41,20 +67,58 @@ type dialer interface {
 //      type dialer interface {
 //          Dial(network, addr string) (net.Conn, error)
 //      }
-func NewDistributer(addr string, listener net.Listener) Distributer {
-	connsMap := make(map[string]chan net.Conn)
-	closeCh := make(chan error, 1)
-	d := &distributer{listener, addr, connsMap, &sync.Mutex{}, closeCh}
+//
+// Optional behavior, like admission control, can be configured by providing
+// DistributerOptions.
+func NewDistributer(addr string, listener net.Listener, opts ...DistributerOption) Distributer {
+	d := &distributer{
+		listener: listener,
+		addr:     addr,
+		connsMap: make(map[string]chan net.Conn),
+		l:        &sync.Mutex{},
+		closeCh:  make(chan error, 1),
+		running:  make(map[*distRunner]*remoteRun),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
 	go d.start()
 	return d
 }
 
+// DistributerOption configures optional behavior of a Distributer. See
+// NewDistributer
+type DistributerOption func(*distributer)
+
+// WithAdmission returns a DistributerOption that limits the Runners received
+// from other nodes according to the provided Admission
+func WithAdmission(a Admission) DistributerOption {
+	return func(d *distributer) {
+		d.admission = newAdmitter(a)
+	}
+}
+
 type distributer struct {
 	listener net.Listener
 	addr     string
 	connsMap map[string]chan net.Conn
 	l        sync.Locker
 	closeCh  chan error
+
+	// in-flight runners that were received from other nodes, guarded by l
+	running      map[*distRunner]*remoteRun
+	runningWg    sync.WaitGroup
+	shuttingDown bool
+
+	admission *admitter // nil when there's no admission control
+	tracer    Tracer    // traces runners received from other nodes
+}
+
+// remoteRun holds the state of a single in-flight runner that was received
+// from another node
+type remoteRun struct {
+	cancel  context.CancelFunc
+	aborted bool
 }
 
 func (d *distributer) start() error {
@@ -75,11 +139,78 @@ func (d *distributer) Close() error {
 	// wait for start() above to exit. otherwise, attempts to re-bind to the
 	// same address will infrequently fail with "bind: address already in use".
 	// because while the listener is closed, there's still one pending Accept()
-	// TODO: consider waiting for all served connections/runners?
+	// NOTE: in-flight runners are not waited for, use Shutdown() for that
 	<-d.closeCh
 	return err
 }
 
+func (d *distributer) Shutdown(ctx context.Context) error {
+	d.l.Lock()
+	d.shuttingDown = true
+	d.l.Unlock()
+
+	// wait for in-flight runners. Note that the listener must remain open in
+	// the meantime, as these runners might still need to establish data
+	// connections for their exchanges
+	done := make(chan struct{})
+	go func() {
+		d.runningWg.Wait()
+		close(done)
+	}()
+
+	var aborted []string
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.l.Lock()
+		execIDs := make(map[string]bool, len(d.running))
+		for r, run := range d.running {
+			run.aborted = true
+			run.cancel()
+			if !execIDs[r.ExecID] {
+				execIDs[r.ExecID] = true
+				aborted = append(aborted, r.ExecID)
+			}
+		}
+		d.l.Unlock()
+		sort.Strings(aborted)
+	}
+
+	err := d.Close()
+	if len(aborted) > 0 {
+		return &ShutdownError{aborted}
+	}
+	return err
+}
+
+// track registers a runner that was received from another node as in-flight,
+// and returns the context to run it with. Returns false if the distributer is
+// shutting down, and thus the runner should be rejected
+func (d *distributer) track(r *distRunner) (context.Context, bool) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.shuttingDown {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.running[r] = &remoteRun{cancel: cancel}
+	d.runningWg.Add(1)
+	return ctx, true
+}
+
+// untrack removes a runner that was previously registered via track(), and
+// returns true if it was aborted due to shutdown
+func (d *distributer) untrack(r *distRunner) bool {
+	d.l.Lock()
+	defer d.l.Unlock()
+	run := d.running[r]
+	run.cancel()
+	delete(d.running, r)
+	d.runningWg.Done()
+	return run.aborted
+}
+
 func (d *distributer) Dial(network, addr string) (conn net.Conn, err error) {
 	if d.closeCh == nil {
 		return nil, io.ErrClosedPipe
@@ -105,7 +236,7 @@ func (d *distributer) Dial(network, addr string) (conn net.Conn, err error) {
 }
 
 func (d *distributer) Distribute(runner Runner, addrs ...string) Runner {
-	return &distRunner{runner, addrs, d.addr, d}
+	return &distRunner{Runner: runner, Addrs: addrs, MasterAddr: d.addr, ExecID: newExecID(), d: d}
 }
 
 // Connect to a node address for the given uid. Used by the individual exchange
@@ -181,23 +312,48 @@ func (d *distributer) Serve(conn net.Conn) error {
 			return err
 		}
 
-		// drain the output
-		// generally - if we're always using Gather, the output will be empty
-		// perhaps we want to log/return an error when some of the data is
-		// discarded here?
-		out := make(chan Dataset)
-		go drain(out)
+		// acknowledge the runner once it's admitted, or reject it if we're
+		// shutting down. The master doesn't start running until all peers have
+		// acknowledged, otherwise it might wait on exchanges with rejecting peers
+		enc := gob.NewEncoder(conn)
+		err = d.admission.admit(r.Priority)
+		if err != nil {
+			return enc.Encode(&req{NewRemoteError(d.addr, err)})
+		}
+		defer d.admission.release()
+
+		ctx, ok := d.track(r)
+		if !ok {
+			return enc.Encode(&req{NewRemoteError(d.addr, ErrShutdown)})
+		}
+
+		err = enc.Encode(&req{})
+		if err != nil {
+			d.untrack(r)
+			log.Println("ep: runner error", err)
+			return err
+		}
+
+		// wait for the master to start the runner after all of the peers have
+		// acknowledged it, or to abort it when one of them rejected it. Aborted
+		// runners never run, and thus aren't reported back
+		start := &req{}
+		err = dec.Decode(start)
+		if err != nil || start.Payload != nil {
+			d.untrack(r)
+			return err
+		}
 
-		inp := make(chan Dataset, 1)
-		close(inp)
+		err = r.runPeer(ctx, enc)
+		if d.untrack(r) {
+			err = ErrShutdown
+		}
 
-		Run(context.Background(), r, inp, out, nil, &err)
 		if err != nil {
-			err = &errMsg{err.Error()}
+			err = NewRemoteError(d.addr, err)
 		}
 
 		// report back to master - either local error or nil payload
-		enc := gob.NewEncoder(conn)
 		err = enc.Encode(&req{err})
 		if err != nil {
 			log.Println("ep: runner error", err)
@@ -227,20 +383,197 @@ func (d *distributer) connCh(k string) chan net.Conn {
 // distributes the runner to all nodes and runs them in parallel.
 type distRunner struct {
 	Runner
-	Addrs      []string // participating node addresses
-	MasterAddr string   // the master node that created the distRunner
+	Addrs      []string       // participating node addresses
+	MasterAddr string         // the master node that created the distRunner
+	PeerOutput PeerOutputMode // handling of the output produced on peers
+	Priority   int            // admission priority on peers, see Admission
+	ExecID     string         // unique ID of this execution, shared by all nodes
+	Retries    int            // max re-dispatches upon peers failures
+	Stats      bool           // collect and report metrics back, see WithStats
+	Path       string         // path of the runner within the executed runner
+	Trace      SpanContext    // the master's span, parent of the peers' spans
+	Codec      string         // of the datasets sent by exchanges, see WithCodec
 	d          *distributer
 }
 
+func newExecID() string {
+	uid, _ := uuid.NewV4()
+	return uid.String()
+}
+
+// PeerOutputMode determines how a distributed Runner handles the output that
+// it produces on peer nodes. Generally, distributed Runners are expected to end
+// with Gather, so only the master node produces output.
+type PeerOutputMode int
+
+const (
+	// DrainPeerOutput silently discards the output produced on peers. This is
+	// the default mode
+	DrainPeerOutput PeerOutputMode = iota
+
+	// StrictPeerOutput fails the Runner with ErrPeerOutput when any output is
+	// produced on peers
+	StrictPeerOutput
+
+	// StreamPeerOutput streams the output produced on peers back to the master
+	// node, where it's emitted along with the master's own output
+	StreamPeerOutput
+)
+
+// ErrPeerOutput is returned by distributed Runners in StrictPeerOutput mode
+// when output was produced on a peer node
+var ErrPeerOutput = errors.New("ep: output produced on peer outside of Gather")
+
+var _ = Errors.Register("peer_output", ErrPeerOutput)
+
+// SetPeerOutput sets the handling of the output produced on peer nodes for a
+// Runner returned by Distributer.Distribute. See PeerOutputMode
+func SetPeerOutput(r Runner, mode PeerOutputMode) Runner {
+	dist, ok := r.(*distRunner)
+	if !ok {
+		panic("SetPeerOutput requires a distributed runner")
+	}
+	dist.PeerOutput = mode
+	return dist
+}
+
+// SetPriority sets the admission priority on peer nodes for a Runner returned
+// by Distributer.Distribute. Higher priorities are admitted first. See Admission
+func SetPriority(r Runner, priority int) Runner {
+	dist, ok := r.(*distRunner)
+	if !ok {
+		panic("SetPriority requires a distributed runner")
+	}
+	dist.Priority = priority
+	return dist
+}
+
+// SetRetries sets the maximum number of times a Runner returned by
+// Distributer.Distribute is re-dispatched when one of the peers fails to accept
+// it. Each retry excludes the failed peer from the participating nodes. Retries
+// only apply to runners that are safe to retry, see IsIdempotent
+func SetRetries(r Runner, retries int) Runner {
+	dist, ok := r.(*distRunner)
+	if !ok {
+		panic("SetRetries requires a distributed runner")
+	}
+	dist.Retries = retries
+	return dist
+}
+
 func (r *distRunner) Equals(other interface{}) bool {
 	o, ok := other.(*distRunner)
-	return ok && r.MasterAddr == o.MasterAddr && o.Runner.Equals(r.Runner)
+	return ok && r.MasterAddr == o.MasterAddr && r.PeerOutput == o.PeerOutput &&
+		o.Runner.Equals(r.Runner)
+}
+
+func (r *distRunner) Idempotent() bool { return IsIdempotent(r.Runner) }
+
+// runPeer runs the distributed runner on a peer node, and handles its output
+// according to the PeerOutput mode. Streamed output is encoded to enc
+func (r *distRunner) runPeer(ctx context.Context, enc encoder) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx = context.WithValue(ctx, pathKey, r.Path)
+	if r.Stats {
+		ctx = WithStats(ctx)
+	}
+	if r.Codec != "" {
+		ctx = WithCodec(ctx, r.Codec)
+	}
+
+	// trace the fragment that runs on this peer as a child of the master's
+	// span, see Tracer
+	if r.d.tracer != nil {
+		ctx = WithTracer(ctx, r.d.tracer)
+		ctx = context.WithValue(ctx, spanKey, r.Trace)
+
+		var span Span
+		ctx, span = startSpan(ctx, "fragment")
+		span.SetAttribute("node", r.d.addr)
+		span.SetAttribute("exec", r.ExecID)
+		defer func() { span.End(err) }()
+	}
+
+	inp := make(chan Dataset, 1)
+	close(inp)
+
+	out := make(chan Dataset)
+	outErr := make(chan error, 1)
+	go func() {
+		var err error
+		for data := range out {
+			if err != nil {
+				continue // already failed, drain the rest
+			}
+
+			switch r.PeerOutput {
+			case StrictPeerOutput:
+				err = ErrPeerOutput
+			case StreamPeerOutput:
+				err = enc.Encode(&req{data})
+			}
+			if err != nil {
+				cancel()
+			}
+		}
+		outErr <- err
+	}()
+
+	Run(ctx, r, inp, out, nil, &err)
+
+	// wait for the output to be handled before reporting back to master, as
+	// it might still be streaming
+	if e := <-outErr; err == nil {
+		err = e
+	}
+
+	// report the collected metrics back to the master before the final
+	// response, see WithStats
+	if stats := GetStats(ctx); stats != nil {
+		if e := enc.Encode(&req{&statsReport{stats.All()}}); err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
+	// the state of this run is set on a copy, as the same runner might run
+	// concurrently, e.g. when it's cached by PlanCache
+	next := *r
+	next.Stats = GetStats(ctx) != nil
+	next.Path = pathOf(ctx)
+	next.Trace = spanContext(ctx)
+	next.Codec = codecName(ctx)
+
+	attempt := &next
+	for retries := r.Retries; ; retries-- {
+		failedAddr, err := attempt.run(ctx, inp, out)
+		if failedAddr == "" || retries <= 0 || !IsIdempotent(r.Runner) {
+			return err
+		}
+
+		// the runner was never started, as one of the peers failed to accept
+		// it. Re-dispatch it without that peer, under a new execution ID in
+		// order to not collide with the exchanges of the failed attempt
+		log.Println("ep: retrying runner without node", failedAddr, err)
+		retry := *attempt
+		retry.Addrs = remove(attempt.Addrs, failedAddr)
+		retry.ExecID = newExecID()
+		attempt = &retry
+	}
+}
+
+// run the distributed runner once. If one of the peers fails to accept the
+// runner, its address is returned along with the error
+func (r *distRunner) run(ctx context.Context, inp, out chan Dataset) (failedAddr string, err error) {
 	var errs []error
 
+	var encs []*gob.Encoder
 	var decs []*gob.Decoder
+	var peers []string // of the encoders and decoders
 	isMain := r.d.addr == r.MasterAddr
 	for i := 0; i < len(r.Addrs) && isMain; i++ {
 		addr := r.Addrs[i]
@@ -251,6 +584,7 @@ func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
 		conn, err := r.d.Dial("tcp", addr)
 		if err != nil {
 			errs = append(errs, err)
+			failedAddr = addr
 			break
 		}
 
@@ -258,6 +592,7 @@ func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
 		err = writeStr(conn, "X") // runner connection
 		if err != nil {
 			errs = append(errs, err)
+			failedAddr = addr
 			break
 		}
 
@@ -265,16 +600,54 @@ func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
 		err = enc.Encode(r)
 		if err != nil {
 			errs = append(errs, err)
+			failedAddr = addr
 			break
 		}
 
+		encs = append(encs, enc)
 		decs = append(decs, gob.NewDecoder(conn))
+		peers = append(peers, addr)
+	}
+
+	// wait for all of the peers to acknowledge the runner, see Serve(). The
+	// runner is dispatched to all of them first, so that peers that queue for
+	// admission wait concurrently
+	for i := 0; i < len(decs) && len(errs) == 0; i++ {
+		ack := &req{}
+		err := decs[i].Decode(ack)
+		if err == nil {
+			err, _ = ack.Payload.(error)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			failedAddr = peers[i]
+		}
+	}
+
+	// start the peers only after all of them were admitted, as the admitted
+	// peers would otherwise wait on exchanges with the queued ones. When any
+	// of them failed, the rest are aborted without running
+	var started []*gob.Decoder
+	for i, enc := range encs {
+		if len(errs) > 0 {
+			enc.Encode(&req{NewRemoteError(r.d.addr, errs[0])}) // best effort
+			continue
+		}
+
+		err := enc.Encode(&req{})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		started = append(started, decs[i])
 	}
+	decs = started
 
 	ctx = context.WithValue(ctx, allNodesKey, r.Addrs)
 	ctx = context.WithValue(ctx, masterNodeKey, r.MasterAddr)
 	ctx = context.WithValue(ctx, thisNodeKey, r.d.addr)
 	ctx = context.WithValue(ctx, distributerKey, r.d)
+	ctx = context.WithValue(ctx, execIDKey, r.ExecID)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -287,7 +660,7 @@ func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := r.Runner.Run(ctx, inp, out)
+			err := runInstrumented(withPath(ctx, "distribute", -1), r.Runner, inp, out)
 			if err != nil {
 				respErrs <- err
 			}
@@ -304,11 +677,36 @@ func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
 		go func(decoder *gob.Decoder) {
 			defer wg.Done()
 
-			req := &req{}
-			err := decoder.Decode(req)
-			data := req.Payload
-			if err == nil {
-				err, _ = data.(error)
+			// receive the streamed output until the final response, see
+			// StreamPeerOutput
+			resp := &req{}
+			err := decoder.Decode(resp)
+			for err == nil {
+				if report, ok := resp.Payload.(*statsReport); ok {
+					if stats := GetStats(ctx); stats != nil {
+						stats.add(report.Stats...)
+					}
+					resp = &req{}
+					err = decoder.Decode(resp)
+					continue
+				}
+
+				data, isData := resp.Payload.(Dataset)
+				if !isData {
+					err, _ = resp.Payload.(error)
+					break
+				}
+
+				select {
+				case out <- data:
+				case <-ctx.Done(): // canceled, keep reading for the final response
+				}
+				resp = &req{}
+				err = decoder.Decode(resp)
+			}
+			if errors.Is(err, ErrIgnorable) {
+				// peer has exited early without failing, see ErrIgnorable
+				return
 			}
 			if err != nil && err.Error() != io.EOF.Error() {
 				cancel()
@@ -326,7 +724,7 @@ func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
 
 	var finalError error
 	for _, e := range errs {
-		if e != errOnPeer {
+		if !errors.Is(e, errOnPeer) {
 			finalError = e
 			break
 		}
@@ -337,7 +735,7 @@ func (r *distRunner) Run(ctx context.Context, inp, out chan Dataset) error {
 			finalError = e
 		}
 	}
-	return finalError
+	return failedAddr, finalError
 }
 
 // write a null-terminated string to a writer
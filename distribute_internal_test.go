@@ -0,0 +1,174 @@
+package ep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ = registerGob(&blockUntilCanceled{})
+
+// blockUntilCanceled is a Runner that produces nothing until it's canceled
+type blockUntilCanceled struct{}
+
+func (*blockUntilCanceled) Equals(other interface{}) bool {
+	_, ok := other.(*blockUntilCanceled)
+	return ok
+}
+func (*blockUntilCanceled) Returns() []Type { return []Type{str} }
+func (*blockUntilCanceled) Run(ctx context.Context, inp, out chan Dataset) error {
+	<-ctx.Done()
+	return nil
+}
+
+// waitForRunning blocks until the distributer has n in-flight runners
+func waitForRunning(t *testing.T, d Distributer, n int) {
+	dist := d.(*distributer)
+	for i := 0; i < 100; i++ {
+		dist.l.Lock()
+		running := len(dist.running)
+		dist.l.Unlock()
+		if running == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d in-flight runners", n)
+}
+
+func TestDistributer_Shutdown_idle(t *testing.T) {
+	dists := startCluster(t, ":5551")
+	require.NoError(t, dists[0].Shutdown(context.Background()))
+}
+
+func TestDistributer_Shutdown_abortInFlight(t *testing.T) {
+	dists := startCluster(t, ":5551", ":5552")
+	master, peer := dists[0], dists[1]
+	defer terminateCluster(t, master)
+
+	runner := master.Distribute(Pipeline(&blockUntilCanceled{}, Gather()), ":5551", ":5552")
+
+	var err error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		inp := make(chan Dataset)
+		out := make(chan Dataset)
+		close(inp)
+		go drain(out)
+		Run(context.Background(), runner, inp, out, nil, &err)
+	}()
+
+	waitForRunning(t, peer, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	shutdownErr := peer.Shutdown(ctx)
+
+	var abortedErr *ShutdownError
+	require.True(t, errors.As(shutdownErr, &abortedErr))
+	require.Equal(t, []string{runner.(*distRunner).ExecID}, abortedErr.Aborted)
+
+	wg.Wait()
+	require.True(t, errors.Is(err, ErrShutdown))
+
+	var remoteErr *RemoteError
+	require.True(t, errors.As(err, &remoteErr))
+	require.Equal(t, ":5552", remoteErr.Node)
+}
+
+func TestDistributer_Shutdown_ignoredCancellation(t *testing.T) {
+	dists := startCluster(t, ":5551")
+	d := dists[0].(*distributer)
+
+	// a runner that never returns, regardless of its context
+	stuck := &distRunner{ExecID: "stuck", d: d}
+	_, ok := d.track(stuck)
+	require.True(t, ok)
+	defer d.untrack(stuck)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- d.Shutdown(ctx) }()
+
+	select {
+	case err := <-done:
+		var abortedErr *ShutdownError
+		require.True(t, errors.As(err, &abortedErr))
+		require.Equal(t, []string{"stuck"}, abortedErr.Aborted)
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown didn't return after its context expired")
+	}
+}
+
+func TestDistributer_Shutdown_rejectNewRunners(t *testing.T) {
+	dists := startCluster(t, ":5551", ":5552")
+	master, peer := dists[0], dists[1]
+	defer terminateCluster(t, master)
+
+	// keep the peer shutting down while dispatching the runner
+	blocker := &distRunner{MasterAddr: ":5551", d: peer.(*distributer)}
+	_, ok := blocker.d.track(blocker)
+	require.True(t, ok)
+
+	done := make(chan error)
+	go func() { done <- peer.Shutdown(context.Background()) }()
+	for !isShuttingDown(peer) {
+		time.Sleep(time.Millisecond)
+	}
+
+	runner := master.Distribute(Pipeline(&blockUntilCanceled{}, Gather()), ":5551", ":5552")
+	inp := make(chan Dataset)
+	out := make(chan Dataset)
+	close(inp)
+	go drain(out)
+
+	var err error
+	Run(context.Background(), runner, inp, out, nil, &err)
+	require.True(t, errors.Is(err, ErrShutdown))
+
+	blocker.d.untrack(blocker)
+	require.NoError(t, <-done)
+}
+
+func isShuttingDown(d Distributer) bool {
+	dist := d.(*distributer)
+	dist.l.Lock()
+	defer dist.l.Unlock()
+	return dist.shuttingDown
+}
+
+func TestDistRunner_Run_concurrent(t *testing.T) {
+	dists := startCluster(t, ":5551")
+	defer terminateCluster(t, dists...)
+
+	// the same runner, as returned by a PlanCache, runs concurrently without
+	// modifying its shared state
+	runner := dists[0].Distribute(PassThrough(), ":5551").(*distRunner)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inp := make(chan Dataset, 1)
+			out := make(chan Dataset)
+			inp <- NewDataset(strs{"hello"})
+			close(inp)
+			go drain(out)
+
+			var err error
+			Run(WithStats(context.Background()), runner, inp, out, nil, &err)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.False(t, runner.Stats)
+	require.Empty(t, runner.Path)
+}
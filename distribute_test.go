@@ -1,6 +1,7 @@
 package ep_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/panoplyio/ep"
 	"github.com/panoplyio/ep/eptest"
@@ -47,6 +48,11 @@ func TestDistributer_Distribute_errorFromPeer(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, "error :5552", err.Error())
 	require.Equal(t, 2, data.Width())
+
+	var remoteErr *ep.RemoteError
+	require.True(t, errors.As(err, &remoteErr))
+	require.Equal(t, ":5552", remoteErr.Node)
+	require.Equal(t, "pipeline[2]", remoteErr.Path)
 }
 
 func TestDistributer_Distribute_ignoreErrIgnorable(t *testing.T) {
@@ -70,3 +76,79 @@ func TestDistributer_Distribute_ignoreErrIgnorable(t *testing.T) {
 		})
 	}
 }
+
+func TestDistributer_Distribute_peerOutput(t *testing.T) {
+	var tests = []struct {
+		name        string
+		mode        ep.PeerOutputMode
+		expected    []string
+		expectedErr error
+	}{
+		{name: "drain", mode: ep.DrainPeerOutput, expected: []string{"world", "bar"}},
+		{name: "strict", mode: ep.StrictPeerOutput, expectedErr: ep.ErrPeerOutput},
+		{name: "stream", mode: ep.StreamPeerOutput, expected: []string{"hello", "world", "foo", "bar"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dist1 := eptest.NewPeer(t, ":5551")
+			dist2 := eptest.NewPeer(t, ":5552")
+			defer eptest.ClosePeer(t, dist1)
+			defer eptest.ClosePeer(t, dist2)
+
+			// no Gather - each node produces its own output
+			runner := dist1.Distribute(ep.Scatter(), ":5551", ":5552")
+			runner = ep.SetPeerOutput(runner, tc.mode)
+
+			data1 := ep.NewDataset(strs{"hello", "world"})
+			data2 := ep.NewDataset(strs{"foo", "bar"})
+			data, err := eptest.Run(runner, data1, data2)
+
+			if tc.expectedErr != nil {
+				require.True(t, errors.Is(err, tc.expectedErr))
+				return
+			}
+
+			require.NoError(t, err)
+			require.ElementsMatch(t, tc.expected, data.At(0).Strings())
+		})
+	}
+}
+
+func TestDistributer_Distribute_retry(t *testing.T) {
+	var tests = []struct {
+		name        string
+		runner      ep.Runner
+		retries     int
+		expectedErr string
+	}{
+		{name: "idempotent", runner: ep.Pipeline(ep.Scatter(), ep.Gather()), retries: 1},
+		{name: "no retries", runner: ep.Pipeline(ep.Scatter(), ep.Gather()), retries: 0, expectedErr: "dial tcp :5000: connect: connection refused"},
+		{name: "not idempotent", runner: ep.Pipeline(ep.Scatter(), &upper{}, ep.Gather()), retries: 1, expectedErr: "dial tcp :5000: connect: connection refused"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dist1 := eptest.NewPeer(t, ":5551")
+			dist2 := eptest.NewPeer(t, ":5552")
+			defer eptest.ClosePeer(t, dist1)
+			defer eptest.ClosePeer(t, dist2)
+
+			// :5000 isn't listening, thus fails to accept the runner
+			runner := dist1.Distribute(tc.runner, ":5551", ":5000", ":5552")
+			runner = ep.SetRetries(runner, tc.retries)
+
+			data1 := ep.NewDataset(strs{"hello", "world"})
+			data2 := ep.NewDataset(strs{"foo", "bar"})
+			data, err := eptest.Run(runner, data1, data2)
+
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"hello", "world", "foo", "bar"}, data.At(0).Strings())
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package driver_test
+
+import (
+	"fmt"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/compare"
+)
+
+var _ = ep.Types.Register("string", str)
+
+var str = &strType{}
+
+type strType struct{}
+
+func (s *strType) String() string   { return s.Name() }
+func (*strType) Name() string       { return "string" }
+func (*strType) Size() uint         { return 8 }
+func (*strType) Data(n int) ep.Data { return make(strs, n) }
+func (*strType) Builder() ep.DataBuilder {
+	return &strBuilder{}
+}
+
+type strBuilder struct{ res strs }
+
+func (b *strBuilder) Append(data ep.Data) { b.res = append(b.res, data.(strs)...) }
+func (b *strBuilder) Data() ep.Data       { return b.res }
+
+type strs []string
+
+func (strs) Type() ep.Type         { return str }
+func (vs strs) Len() int           { return len(vs) }
+func (vs strs) Less(i, j int) bool { return vs[i] < vs[j] }
+func (vs strs) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+func (vs strs) LessOther(thisRow int, other ep.Data, otherRow int) bool {
+	return vs[thisRow] < other.(strs)[otherRow]
+}
+func (vs strs) Slice(s, e int) ep.Data { return vs[s:e] }
+func (vs strs) Duplicate(t int) ep.Data {
+	ans := make(strs, 0, vs.Len()*t)
+	for i := 0; i < t; i++ {
+		ans = append(ans, vs...)
+	}
+	return ans
+}
+func (vs strs) IsNull(i int) bool { return false }
+func (vs strs) MarkNull(i int)    {}
+func (vs strs) Nulls() []bool     { return make([]bool, vs.Len()) }
+func (vs strs) Equal(other ep.Data) bool {
+	return fmt.Sprintf("%p", vs) == fmt.Sprintf("%p", other)
+}
+func (vs strs) Compare(other ep.Data) ([]compare.Result, error) {
+	res := make([]compare.Result, vs.Len())
+	for i, v := range other.(strs) {
+		switch {
+		case vs[i] == v:
+			res[i] = compare.Equal
+		case vs[i] > v:
+			res[i] = compare.Greater
+		default:
+			res[i] = compare.Less
+		}
+	}
+	return res, nil
+}
+func (vs strs) Copy(from ep.Data, fromRow, toRow int) { vs[toRow] = from.(strs)[fromRow] }
+func (vs strs) CopyNTimes(from ep.Data, fromRow, toRow int, duplications []int) {
+	for i, n := range duplications {
+		for j := 0; j < n; j++ {
+			vs[toRow+j] = from.(strs)[fromRow+i]
+		}
+		toRow += n
+	}
+}
+func (vs strs) CopyByIndexes(from ep.Data, fromRows []int, toRow int) {
+	for i, idx := range fromRows {
+		vs[toRow+i] = from.(strs)[idx]
+	}
+}
+func (vs strs) Strings() []string { return vs }
@@ -0,0 +1,187 @@
+// Package driver exposes ep runners through the database/sql package. Queries
+// are planned into runners by a user-provided Planner, and executed by a
+// Connector that can be opened with sql.OpenDB, or by a Driver that can be
+// registered with sql.Register:
+//
+//      planner := func(ctx context.Context, query string, args []driver.NamedValue) (ep.Runner, error) {
+//          return ep.Plan(ctx, parse(query, args))
+//      }
+//
+//      db := sql.OpenDB(epdriver.NewConnector(planner))
+//      rows, err := db.QueryContext(ctx, "SELECT * FROM t WHERE id = ?", 1)
+//
+// The planned runners are executed like ep.Rows for queries, unless they're
+// already created by ep.Rows, or by ep.MultiRows for queries that return
+// multiple result sets. For statements executed with Exec, runners that
+// implement ep.RunnerExec are executed with their Exec, while other runners
+// are run to completion, and their number of output rows is reported as the
+// number of affected rows.
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/panoplyio/ep"
+)
+
+// Planner plans the runner that executes the query with the provided args.
+// The args are converted to the driver.Value types by database/sql, and their
+// placeholders are left for the Planner to resolve, see NumInput
+type Planner func(ctx context.Context, query string, args []driver.NamedValue) (ep.Runner, error)
+
+// ErrTxNotSupported is returned when beginning a transaction, as runners are
+// executed without transactions
+var ErrTxNotSupported = errors.New("ep: transactions are not supported")
+
+// Driver is a driver.Driver that plans all queries with its Planner,
+// regardless of the data source name. It's registered with:
+//
+//      sql.Register("ep", &epdriver.Driver{Planner: planner})
+//      db, err := sql.Open("ep", "")
+type Driver struct {
+	Planner Planner
+}
+
+// Open implements driver.Driver
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	return &conn{d.Planner}, nil
+}
+
+// OpenConnector implements driver.DriverContext
+func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
+	return &Connector{d}, nil
+}
+
+// Connector is a driver.Connector that plans all queries with a Planner, see
+// NewConnector
+type Connector struct {
+	d *Driver
+}
+
+// NewConnector returns a new Connector that plans all queries with the
+// provided Planner, to be opened with sql.OpenDB
+func NewConnector(planner Planner) *Connector {
+	return &Connector{&Driver{planner}}
+}
+
+// Connect implements driver.Connector
+func (c *Connector) Connect(context.Context) (driver.Conn, error) {
+	return c.d.Open("")
+}
+
+// Driver implements driver.Connector
+func (c *Connector) Driver() driver.Driver { return c.d }
+
+// conn is a driver.Conn that plans its queries with the Planner. It holds
+// no state, as runners are planned and executed per query
+type conn struct {
+	planner Planner
+}
+
+var _ driver.QueryerContext = &conn{}
+var _ driver.ExecerContext = &conn{}
+var _ driver.ConnPrepareContext = &conn{}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return &stmt{c, query}, nil
+}
+
+func (*conn) Close() error { return nil }
+
+func (*conn) Begin() (driver.Tx, error) { return nil, ErrTxNotSupported }
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r, err := c.planner(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if rows, ok := r.(driver.Rows); ok {
+		return rows, nil // already created by ep.Rows or ep.MultiRows
+	}
+	return ep.Rows(ctx, r).(driver.Rows), nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r, err := c.planner(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if exec, ok := r.(ep.RunnerExec); ok {
+		lastID, rowsAffected, err := exec.Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ep.Result(lastID, rowsAffected), nil
+	}
+
+	rowsAffected, err := run(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return ep.Result(0, rowsAffected), nil
+}
+
+// run the runner to completion, and return its number of output rows
+func run(ctx context.Context, r ep.Runner) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	inp, out := make(chan ep.Dataset), make(chan ep.Dataset)
+	close(inp) // no input
+
+	var err error
+	go func() {
+		defer close(out)
+		err = r.Run(ctx, inp, out)
+	}()
+
+	var rows int64
+	for data := range out {
+		rows += int64(data.Len())
+	}
+	return rows, err
+}
+
+// stmt is a prepared statement, that's planned whenever it's executed, as
+// the planned runner depends on the args
+type stmt struct {
+	c     *conn
+	query string
+}
+
+var _ driver.StmtQueryContext = &stmt{}
+var _ driver.StmtExecContext = &stmt{}
+
+func (*stmt) Close() error { return nil }
+
+func (s *stmt) NumInput() int { return NumInput(s.query) }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	res := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		res[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return res
+}
@@ -0,0 +1,186 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/panoplyio/ep"
+	epdriver "github.com/panoplyio/ep/driver"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// values is a runner that produces its values, ignoring its input
+type values struct{ Values []string }
+
+func (*values) Equals(other interface{}) bool { return false }
+func (*values) Returns() []ep.Type            { return []ep.Type{ep.SetAlias(str, "value")} }
+func (r *values) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for range inp {
+	}
+	out <- ep.NewDataset(strs(r.Values))
+	return nil
+}
+
+// insert is a runner that's executed without running it
+type insert struct{ values }
+
+func (r *insert) Exec(context.Context) (int64, int64, error) {
+	return 7, int64(len(r.Values)), nil
+}
+
+// planner plans queries of the form "<verb> ?, ?, ...", by producing the
+// args of the query
+func planner(ctx context.Context, query string, args []driver.NamedValue) (ep.Runner, error) {
+	vals := make([]string, len(args))
+	for i, arg := range args {
+		vals[i] = fmt.Sprint(arg.Value)
+	}
+
+	switch {
+	case len(query) >= 6 && query[:6] == "SELECT":
+		return &values{vals}, nil
+	case len(query) >= 6 && query[:6] == "INSERT":
+		return &insert{values{vals}}, nil
+	}
+	return nil, fmt.Errorf("unsupported query: %s", query)
+}
+
+func TestConnector(t *testing.T) {
+	db := sql.OpenDB(epdriver.NewConnector(planner))
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT ?, ?", "hello", 5)
+	require.NoError(t, err)
+
+	cols, err := rows.Columns()
+	require.NoError(t, err)
+	require.Equal(t, []string{"value"}, cols)
+
+	var res []string
+	for rows.Next() {
+		var v string
+		require.NoError(t, rows.Scan(&v))
+		res = append(res, v)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.Equal(t, []string{"hello", "5"}, res)
+
+	_, err = db.Query("DELETE")
+	require.EqualError(t, err, "unsupported query: DELETE")
+}
+
+func TestConnector_exec(t *testing.T) {
+	db := sql.OpenDB(epdriver.NewConnector(planner))
+	defer db.Close()
+
+	// runners that implement ep.RunnerExec
+	res, err := db.Exec("INSERT ?, ?", "a", "b")
+	require.NoError(t, err)
+	lastID, err := res.LastInsertId()
+	require.NoError(t, err)
+	require.Equal(t, int64(7), lastID)
+	rowsAffected, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rowsAffected)
+
+	// other runners are run
+	res, err = db.Exec("SELECT ?, ?, ?", "a", "b", "c")
+	require.NoError(t, err)
+	rowsAffected, err = res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rowsAffected)
+}
+
+func TestConnector_prepare(t *testing.T) {
+	db := sql.OpenDB(epdriver.NewConnector(planner))
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT $1, $2")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	var v string
+	require.NoError(t, stmt.QueryRow("hello", "world").Scan(&v))
+	require.Equal(t, "hello", v)
+
+	_, err = stmt.Query("hello")
+	require.EqualError(t, err, "sql: expected 2 arguments, got 1")
+
+	_, err = db.Begin()
+	require.Equal(t, epdriver.ErrTxNotSupported, err)
+}
+
+func init() {
+	sql.Register("ep", &epdriver.Driver{Planner: planner})
+}
+
+func TestDriver(t *testing.T) {
+	db, err := sql.Open("ep", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var v string
+	require.NoError(t, db.QueryRow("SELECT ?", "hello").Scan(&v))
+	require.Equal(t, "hello", v)
+
+	// the number of args of prepared statements is verified
+	stmt, err := db.Prepare("SELECT 1")
+	require.NoError(t, err)
+	defer stmt.Close()
+	_, err = stmt.Query("extra")
+	require.EqualError(t, err, "sql: expected 0 arguments, got 1")
+}
+
+func TestNumInput(t *testing.T) {
+	tests := map[string]int{
+		"SELECT 1":                      0,
+		"SELECT ?, ?":                   2,
+		"SELECT $1, $2, $1":             2,
+		"SELECT $10":                    10,
+		"SELECT '?', \"$3\", ?":         1,
+		"SELECT 'it''s ?', ?":           1,
+		"SELECT ? -- ?\n, ?":            2,
+		"SELECT /* $5 ? */ $1":          1,
+		"SELECT a::int, ':a', ?":        1,
+		"SELECT ? FROM t WHERE a = :a":  -1,
+		"SELECT * FROM t WHERE a = :a":  -1,
+		"SELECT * FROM t WHERE a = @a":  -1,
+		"SELECT ?, $1":                  -1,
+		"SELECT `?` FROM t WHERE a = ?": 1,
+	}
+	for query, expected := range tests {
+		require.Equal(t, expected, epdriver.NumInput(query), query)
+	}
+}
+
+func TestConnector_multipleResultSets(t *testing.T) {
+	planner := func(ctx context.Context, query string, args []driver.NamedValue) (ep.Runner, error) {
+		return ep.MultiRows(ctx, &values{[]string{"a", "b"}}, &values{[]string{"c"}}), nil
+	}
+	db := sql.OpenDB(epdriver.NewConnector(planner))
+	defer db.Close()
+
+	rows, err := db.Query("SELECT a; SELECT c")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var res [][]string
+	for {
+		var set []string
+		for rows.Next() {
+			var v string
+			require.NoError(t, rows.Scan(&v))
+			set = append(set, v)
+		}
+		res = append(res, set)
+
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, [][]string{{"a", "b"}, {"c"}}, res)
+}
@@ -0,0 +1,63 @@
+package driver
+
+// NumInput returns the number of positional placeholders in the query, which
+// are either question marks (?), or numbered dollar signs ($1, $2, etc.) in
+// which case the highest number is returned. Placeholders within quoted
+// strings and identifiers, and within comments, are ignored. It returns -1
+// when the placeholders can't be counted, as the query uses named ones (:name
+// or @name) or mixes both kinds of positional ones, in which case database/sql
+// doesn't verify the number of args.
+func NumInput(query string) int {
+	var questions, dollars int
+	var named bool
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipUntil(query, i+1, string(c))
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			i = skipUntil(query, i+2, "\n")
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			i = skipUntil(query, i+2, "*/")
+		case c == '?':
+			questions++
+		case c == '$':
+			n := 0
+			for i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9' {
+				n = n*10 + int(query[i+1]-'0')
+				i++
+			}
+			if n > dollars {
+				dollars = n
+			}
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			i++ // a cast, like a::int
+		case (c == ':' || c == '@') && i+1 < len(query) && isNameStart(query[i+1]):
+			named = true
+		}
+	}
+
+	if named || (dollars > 0 && questions > 0) {
+		return -1
+	} else if dollars > 0 {
+		return dollars
+	}
+	return questions
+}
+
+// isNameStart returns true if the byte can start the name of a placeholder
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// skipUntil returns the index of the last byte of the first occurrence of
+// end in the query, starting from the provided index, or the end of the query
+// if there's no such occurrence. Quotes are escaped by doubling them, which
+// is equivalent to ending and immediately re-opening the quoted string
+func skipUntil(query string, i int, end string) int {
+	for ; i+len(end) <= len(query); i++ {
+		if query[i:i+len(end)] == end {
+			return i + len(end) - 1
+		}
+	}
+	return len(query)
+}
@@ -52,6 +52,21 @@
 // land on the same key. This is useful for planning, where we want to match
 // based on instances of that struct. See Planning below.
 //
+// The global registries are shared by the whole process. Engines that need
+// their own runners and types can create a separate Registry, which exposes
+// the same `Runners` and `Types` interface, and plan from it by attaching it
+// to the context with WithRegistry. As registries are safe for concurrent use,
+// the global `Runners` and `Types` aren't maps, so they can't be indexed or
+// ranged over like they used to. Use their Get() and Types.All() instead.
+//
+// Similarly, the global `Errors` registry maps codes to errors that should be
+// recognizable on the master node when they occur on remote peers. See
+// RemoteError for more details. Errors returned by the runners composed in a
+// Pipeline, Project or Union are wrapped with the position of the failing
+// runner, so they should be matched with errors.Is rather than with ==. The
+// global `Codecs` registry maps names to the codecs of the datasets that
+// exchanges send between nodes, see WithCodec.
+//
 // Planning
 //
 // Planning is the process of constructing Runners based on some configuration
@@ -78,7 +93,10 @@
 // Plan will fallthrough to the next Runner of the same key, thus implementing a
 // kind of middleware systems where at least one RunnerPlan must succeed. This
 // allows an opportunistic design where several runners bind to the same node,
-// each planning it differently - if they can.
+// each planning it differently - if they can. Runners are planned in the order
+// of their registration, unless registered with a higher priority using
+// Runners.RegisterWithPriority. When all of them fail, the returned PlanError
+// lists the reason that each of them was rejected.
 //
 // Aliasing and Scoping
 //
@@ -109,7 +127,8 @@ func registerGob(es ...interface{}) bool {
 	return true
 }
 
-// Spew returns a debugging string showing the composition of runners
+// Spew returns a debugging string showing the composition of runners. See
+// Explain for a more readable rendering
 func Spew(r Runner) string {
 	return spew.Sdump(r)
 }
@@ -124,6 +143,15 @@ const (
 	distributerKey
 	lockErrorKey
 	errorKey
+	execIDKey
+	statsKey
+	pathKey
+	statsRecorderKey
+	tracerKey
+	spanKey
+	planCacheKey
+	scopedRegistryKey
+	codecKey
 )
 
 // NodeAddress returns the current node address as saved in given context
@@ -176,7 +204,7 @@ func setError(ctx context.Context, err error) {
 	defer mutex.Unlock()
 
 	errPtr := ctx.Value(errorKey).(*error)
-	if *errPtr == nil || *errPtr == errOnPeer {
+	if *errPtr == nil || errors.Is(*errPtr, errOnPeer) {
 		*errPtr = err
 	}
 }
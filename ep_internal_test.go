@@ -164,6 +164,7 @@ func (r *dontCancel) Run(ctx context.Context, inp, out chan Dataset) error {
 	internalCtx = context.WithValue(internalCtx, allNodesKey, ctx.Value(allNodesKey))
 	internalCtx = context.WithValue(internalCtx, masterNodeKey, ctx.Value(masterNodeKey))
 	internalCtx = context.WithValue(internalCtx, thisNodeKey, ctx.Value(thisNodeKey))
+	internalCtx = context.WithValue(internalCtx, execIDKey, ctx.Value(execIDKey))
 	internalCtx = context.WithValue(internalCtx, lockErrorKey, ctx.Value(lockErrorKey))
 	internalCtx = context.WithValue(internalCtx, errorKey, ctx.Value(errorKey))
 
@@ -0,0 +1,381 @@
+// Package eparrow converts ep datasets to and from Apache Arrow records, and
+// exposes runners that read and write Arrow IPC streams:
+//
+//      rec, err := eparrow.ToRecord(runner.Returns(), data)
+//      data, err := eparrow.FromRecord(ctx, rec)
+//
+//      r, err := eparrow.ReadIPC(ctx, conn)
+//      runner := ep.Pipeline(r, project, eparrow.WriteIPC(w, project.Returns()))
+//
+// The built-in types are the Go types of the driver.Value values. Columns of
+// types that implement ep.ScanTyper are converted into the Arrow types of
+// their scan types, with the values of their ep.ValuerData, while all other
+// columns are converted into the strings of their Strings().
+// Arrow columns are converted into the types registered to the names of the
+// Go types of their values in the Types registry of the context (see
+// ep.FromStructs and ep.GetRegistry), and
+// set by their ep.ScannerData: all integers are int64, floats are float64,
+// timestamps and dates are time.Time, and binaries are []uint8. Records are
+// converted into Arrow structs of their columns, and vice versa.
+//
+// The datasets sent between nodes by exchanges can also be encoded as Arrow
+// IPC streams instead of gob, see CodecName.
+package eparrow
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/panoplyio/ep"
+	"math"
+	"reflect"
+	"time"
+)
+
+var timestampType = &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}
+
+// Schema returns the Arrow schema of the types, whose fields are named by the
+// aliases of the types, or ep.UnnamedColumn for unnamed types, and are
+// nullable unless they're declared otherwise by ep.SetNullable. As the
+// columns of records aren't declared by their types, they're empty structs
+func Schema(types []ep.Type) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(types))
+	for i, t := range types {
+		dt := arrow.DataType(arrow.StructOf())
+		if t.Name() != ep.Record.Name() {
+			var err error
+			dt, err = arrowType(t)
+			if err != nil {
+				return nil, err
+			}
+		}
+		fields[i] = field(t, dt)
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// ToRecord returns a new Arrow record of the dataset, whose fields are named
+// by the types, like in Schema, but are typed by the columns of the dataset.
+// The record should be released when it's no longer needed
+func ToRecord(types []ep.Type, data ep.Dataset) (array.Record, error) {
+	if len(types) != data.Width() {
+		return nil, fmt.Errorf("eparrow: can't convert %d columns into %d fields", data.Width(), len(types))
+	}
+
+	fields := make([]arrow.Field, len(types))
+	for i, t := range types {
+		dt, err := dataType(data.At(i))
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field(t, dt)
+	}
+
+	b := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema(fields, nil))
+	defer b.Release()
+	for i := range fields {
+		err := appendData(b.Field(i), data.At(i))
+		if err != nil {
+			return nil, fmt.Errorf("eparrow: can't convert %s: %s", fields[i].Name, err)
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+// FromRecord returns a new dataset of the values of the Arrow record, see
+// Types for the types of its columns
+func FromRecord(ctx context.Context, rec array.Record) (ep.Dataset, error) {
+	reg := ep.GetRegistry(ctx)
+	res := make([]ep.Data, rec.NumCols())
+	for i, col := range rec.Columns() {
+		data, err := fromArray(reg, col)
+		if err != nil {
+			return nil, fmt.Errorf("eparrow: can't convert %s: %s", rec.ColumnName(i), err)
+		}
+		res[i] = data
+	}
+	return ep.NewDataset(res...), nil
+}
+
+// Types returns the types of the datasets returned by FromRecord for records
+// of the Arrow schema, aliased by the names of the fields, and declared as
+// nullable by their nullability
+func Types(ctx context.Context, schema *arrow.Schema) ([]ep.Type, error) {
+	reg := ep.GetRegistry(ctx)
+	res := make([]ep.Type, len(schema.Fields()))
+	for i, f := range schema.Fields() {
+		t, err := epType(reg, f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("eparrow: can't convert %s: %s", f.Name, err)
+		}
+		res[i] = ep.SetNullable(ep.SetAlias(t, f.Name), f.Nullable)
+	}
+	return res, nil
+}
+
+func field(t ep.Type, dt arrow.DataType) arrow.Field {
+	name := ep.GetAlias(t)
+	if name == "" {
+		name = ep.UnnamedColumn
+	}
+
+	nullable, ok := ep.IsNullable(t)
+	return arrow.Field{Name: name, Type: dt, Nullable: nullable || !ok}
+}
+
+// dataType returns the Arrow type of the data, where records are structs of
+// the Arrow types of their columns, named by their positions
+func dataType(data ep.Data) (arrow.DataType, error) {
+	set, ok := data.(ep.Dataset)
+	if !ok {
+		return arrowType(data.Type())
+	}
+
+	fields := make([]arrow.Field, set.Width())
+	for i := range fields {
+		dt, err := dataType(set.At(i))
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = arrow.Field{Name: fmt.Sprintf("f%d", i), Type: dt, Nullable: true}
+	}
+	return arrow.StructOf(fields...), nil
+}
+
+// arrowType returns the Arrow type of the values of the non-record type,
+// regardless of its modifiers, see ep.UnderlyingType
+func arrowType(t ep.Type) (arrow.DataType, error) {
+	scanTyper, ok := ep.UnderlyingType(t).(ep.ScanTyper)
+	if !ok {
+		return arrow.BinaryTypes.String, nil
+	}
+
+	switch scanType := scanTyper.ScanType(); scanType {
+	case reflect.TypeOf(int64(0)):
+		return arrow.PrimitiveTypes.Int64, nil
+	case reflect.TypeOf(float64(0)):
+		return arrow.PrimitiveTypes.Float64, nil
+	case reflect.TypeOf(false):
+		return arrow.FixedWidthTypes.Boolean, nil
+	case reflect.TypeOf(""):
+		return arrow.BinaryTypes.String, nil
+	case reflect.TypeOf([]byte(nil)):
+		return arrow.BinaryTypes.Binary, nil
+	case reflect.TypeOf(time.Time{}):
+		return timestampType, nil
+	default:
+		return nil, fmt.Errorf("eparrow: %s values of %s aren't supported", scanType, t)
+	}
+}
+
+// appendData appends the values of the data to the builder of its Arrow type
+func appendData(b array.Builder, data ep.Data) error {
+	if set, ok := data.(ep.Dataset); ok {
+		sb := b.(*array.StructBuilder)
+		valids := make([]bool, set.Len())
+		for row := range valids {
+			valids[row] = !set.IsNull(row)
+		}
+		sb.AppendValues(valids) // the fields are appended separately
+		for i := 0; i < set.Width(); i++ {
+			err := appendData(sb.FieldBuilder(i), set.At(i))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, v := range values(data) {
+		if v == nil {
+			b.AppendNull()
+			continue
+		}
+
+		var ok bool
+		switch b := b.(type) {
+		case *array.Int64Builder:
+			var n int64
+			n, ok = v.(int64)
+			b.Append(n)
+		case *array.Float64Builder:
+			var f float64
+			f, ok = v.(float64)
+			b.Append(f)
+		case *array.BooleanBuilder:
+			var bl bool
+			bl, ok = v.(bool)
+			b.Append(bl)
+		case *array.StringBuilder:
+			var s string
+			s, ok = v.(string)
+			b.Append(s)
+		case *array.BinaryBuilder:
+			var bs []byte
+			bs, ok = v.([]byte)
+			b.Append(bs)
+		case *array.TimestampBuilder:
+			var t time.Time
+			t, ok = v.(time.Time)
+			b.Append(arrow.Timestamp(t.UnixNano()))
+		}
+		if !ok {
+			return fmt.Errorf("unexpected %T value of %s", v, data.Type())
+		}
+	}
+	return nil
+}
+
+// values returns the values of the data, which are its strings unless its
+// type declares the Go type of its values, see arrowType
+func values(data ep.Data) []driver.Value {
+	res := make([]driver.Value, data.Len())
+	_, scanTyper := ep.UnderlyingType(data.Type()).(ep.ScanTyper)
+	if valuer, ok := data.(ep.ValuerData); ok && scanTyper {
+		for i := range res {
+			res[i] = valuer.Value(i)
+		}
+		return res
+	}
+
+	strs := data.Strings()
+	for i := range res {
+		if !data.IsNull(i) {
+			res[i] = strs[i]
+		}
+	}
+	return res
+}
+
+// epType returns the registered type of the values of the Arrow type, see
+// ep.FromStructs, or ep.Record for structs
+func epType(reg *ep.Registry, dt arrow.DataType) (ep.Type, error) {
+	var key string
+	switch dt.ID() {
+	case arrow.STRUCT:
+		return ep.Record, nil
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+		key = "int64"
+	case arrow.FLOAT32, arrow.FLOAT64:
+		key = "float64"
+	case arrow.BOOL:
+		key = "bool"
+	case arrow.STRING:
+		key = "string"
+	case arrow.BINARY:
+		key = "[]uint8"
+	case arrow.TIMESTAMP, arrow.DATE32, arrow.DATE64:
+		key = "time.Time"
+	default:
+		return nil, fmt.Errorf("%s isn't supported", dt)
+	}
+
+	types := reg.Types.Get(key)
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no type is registered for %s", key)
+	}
+	return types[0], nil
+}
+
+// fromArray returns the data of the values of the Arrow array
+func fromArray(reg *ep.Registry, arr array.Interface) (ep.Data, error) {
+	if st, ok := arr.(*array.Struct); ok {
+		cols := make([]ep.Data, st.NumField())
+		for i := range cols {
+			data, err := fromArray(reg, st.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			cols[i] = data
+		}
+
+		res := ep.NewDataset(cols...)
+		for row := 0; row < st.Len(); row++ {
+			if st.IsNull(row) {
+				res.MarkNull(row)
+			}
+		}
+		return res, nil
+	}
+
+	t, err := epType(reg, arr.DataType())
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := t.Data(arr.Len()).(ep.ScannerData)
+	if !ok {
+		return nil, fmt.Errorf("%s data doesn't implement ScannerData", t.Name())
+	}
+
+	for row := 0; row < arr.Len(); row++ {
+		if arr.IsNull(row) {
+			data.MarkNull(row)
+			continue
+		}
+
+		v, err := value(arr, row)
+		if err != nil {
+			return nil, err
+		}
+
+		err = data.Scan(row, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// value returns the value of the row of the supported Arrow array, see epType,
+// or an error if it's an unsigned integer that overflows int64
+func value(arr array.Interface, row int) (driver.Value, error) {
+	switch arr := arr.(type) {
+	case *array.Int8:
+		return int64(arr.Value(row)), nil
+	case *array.Int16:
+		return int64(arr.Value(row)), nil
+	case *array.Int32:
+		return int64(arr.Value(row)), nil
+	case *array.Int64:
+		return arr.Value(row), nil
+	case *array.Uint8:
+		return int64(arr.Value(row)), nil
+	case *array.Uint16:
+		return int64(arr.Value(row)), nil
+	case *array.Uint32:
+		return int64(arr.Value(row)), nil
+	case *array.Uint64:
+		if arr.Value(row) > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int64", arr.Value(row))
+		}
+		return int64(arr.Value(row)), nil
+	case *array.Float32:
+		return float64(arr.Value(row)), nil
+	case *array.Float64:
+		return arr.Value(row), nil
+	case *array.Boolean:
+		return arr.Value(row), nil
+	case *array.String:
+		return arr.Value(row), nil
+	case *array.Binary:
+		return append([]byte(nil), arr.Value(row)...), nil
+	case *array.Timestamp:
+		unit := arr.DataType().(*arrow.TimestampType).Unit
+		return time.Unix(0, int64(arr.Value(row))*int64(duration(unit))).UTC(), nil
+	case *array.Date32:
+		return time.Unix(int64(arr.Value(row))*24*60*60, 0).UTC(), nil
+	case *array.Date64:
+		return time.Unix(0, int64(arr.Value(row))*int64(time.Millisecond)).UTC(), nil
+	}
+	panic(fmt.Sprintf("eparrow: unexpected %T", arr))
+}
+
+// duration returns the duration of the Arrow time unit
+func duration(unit arrow.TimeUnit) time.Duration {
+	return [...]time.Duration{time.Nanosecond, time.Microsecond, time.Millisecond, time.Second}[unit]
+}
@@ -0,0 +1,172 @@
+package eparrow_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eparrow"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func newValues(t *valueType, vs ...driver.Value) *values { return &values{t, vs} }
+
+// plainValues are values whose type doesn't declare their scan type
+type plainValues struct{ *values }
+
+func (vs *plainValues) Type() ep.Type { return struct{ ep.Type }{vs.T} }
+
+func TestToRecord(t *testing.T) {
+	now := time.Now().UTC()
+	data := ep.NewDataset(
+		newValues(int64Type, int64(1), nil),
+		newValues(float64Type, 1.5, 2.5),
+		newValues(boolType, true, false),
+		newValues(stringType, "a", nil),
+		newValues(bytesType, []byte("b"), []byte{}),
+		newValues(timeType, now, nil),
+		ep.NewDataset(newValues(stringType, "c", nil), newValues(int64Type, int64(2), nil)),
+	)
+	types := []ep.Type{
+		ep.SetNullable(ep.SetAlias(int64Type, "id"), false),
+		float64Type, boolType, stringType, bytesType, timeType, ep.Record,
+	}
+
+	rec, err := eparrow.ToRecord(types, data)
+	require.NoError(t, err)
+	defer rec.Release()
+
+	require.Equal(t, int64(2), rec.NumRows())
+	schema := rec.Schema()
+	require.Equal(t, "id", schema.Field(0).Name)
+	require.False(t, schema.Field(0).Nullable)
+	require.Equal(t, ep.UnnamedColumn, schema.Field(1).Name)
+	require.True(t, schema.Field(1).Nullable)
+	require.Equal(t, arrow.INT64, schema.Field(0).Type.ID())
+	require.Equal(t, arrow.TIMESTAMP, schema.Field(5).Type.ID())
+	require.Equal(t, arrow.STRUCT, schema.Field(6).Type.ID())
+	require.True(t, rec.Column(0).IsNull(1))
+	require.True(t, rec.Column(6).IsNull(1))
+
+	res, err := eparrow.FromRecord(context.Background(), rec)
+	require.NoError(t, err)
+	require.Equal(t, data.Strings(), res.Strings())
+	require.Equal(t, now, res.At(5).(ep.ValuerData).Value(0))
+
+	resTypes, err := eparrow.Types(context.Background(), schema)
+	require.NoError(t, err)
+	require.Equal(t, "id", ep.GetAlias(resTypes[0]))
+	require.Equal(t, "int64", resTypes[0].Name())
+	nullable, _ := ep.IsNullable(resTypes[0])
+	require.False(t, nullable)
+	require.Equal(t, ep.Record.Name(), resTypes[6].Name())
+}
+
+func TestToRecord_strings(t *testing.T) {
+	// types without a scan type are converted into strings
+	data := ep.NewDataset(&plainValues{newValues(int64Type, int64(1), nil)})
+	rec, err := eparrow.ToRecord([]ep.Type{int64Type}, data)
+	require.NoError(t, err)
+	defer rec.Release()
+	require.Equal(t, arrow.STRING, rec.Schema().Field(0).Type.ID())
+	require.Equal(t, "1", rec.Column(0).(*array.String).Value(0))
+	require.True(t, rec.Column(0).IsNull(1))
+
+	_, err = eparrow.ToRecord([]ep.Type{stringType}, ep.NewDataset())
+	require.EqualError(t, err, "eparrow: can't convert 0 columns into 1 fields")
+
+	data = ep.NewDataset(newValues(int64Type, "not an int"))
+	_, err = eparrow.ToRecord([]ep.Type{int64Type}, data)
+	require.EqualError(t, err, "eparrow: can't convert ?column?: unexpected string value of int64")
+}
+
+func TestFromRecord_widening(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "i", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "f", Type: arrow.PrimitiveTypes.Float32},
+		{Name: "d", Type: arrow.FixedWidthTypes.Date32},
+	}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	b.Field(0).(*array.Int32Builder).Append(7)
+	b.Field(1).(*array.Float32Builder).Append(0.5)
+	b.Field(2).(*array.Date32Builder).Append(1)
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	data, err := eparrow.FromRecord(context.Background(), rec)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), data.At(0).(ep.ValuerData).Value(0))
+	require.Equal(t, 0.5, data.At(1).(ep.ValuerData).Value(0))
+	require.Equal(t, time.Unix(24*60*60, 0).UTC(), data.At(2).(ep.ValuerData).Value(0))
+
+	schema = arrow.NewSchema([]arrow.Field{{Name: "u", Type: arrow.PrimitiveTypes.Uint64}}, nil)
+	b = array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	b.Field(0).(*array.Uint64Builder).AppendValues([]uint64{math.MaxInt64, math.MaxUint64}, nil)
+	rec = b.NewRecord()
+	defer rec.Release()
+
+	_, err = eparrow.FromRecord(context.Background(), rec)
+	require.EqualError(t, err, "eparrow: can't convert u: 18446744073709551615 overflows int64")
+
+	schema = arrow.NewSchema([]arrow.Field{{Name: "n", Type: arrow.Null}}, nil)
+	_, err = eparrow.Types(context.Background(), schema)
+	require.EqualError(t, err, "eparrow: can't convert n: null isn't supported")
+}
+
+func TestIPC(t *testing.T) {
+	types := []ep.Type{ep.SetAlias(int64Type, "id"), ep.SetAlias(stringType, "name")}
+	data1 := ep.NewDataset(newValues(int64Type, int64(1), int64(2)), newValues(stringType, "a", nil))
+	data2 := ep.NewDataset(newValues(int64Type, int64(3)), newValues(stringType, "c"))
+
+	var buf bytes.Buffer
+	res, err := eptest.Run(eparrow.WriteIPC(&buf, types), data1, data2)
+	require.NoError(t, err)
+	require.Nil(t, res)
+
+	reader, err := eparrow.ReadIPC(context.Background(), &buf)
+	require.NoError(t, err)
+	require.Equal(t, "id", ep.GetAlias(reader.Returns()[0]))
+	require.Equal(t, int64Type.Name(), reader.Returns()[0].Name())
+
+	schema, err := eparrow.Schema(types)
+	require.NoError(t, err)
+	require.Equal(t, arrow.INT64, schema.Field(0).Type.ID())
+	require.Equal(t, "name", ep.GetAlias(reader.Returns()[1]))
+
+	res, err = eptest.Run(reader)
+	require.NoError(t, err)
+	require.Equal(t, []string{"(1,a)", "(2,)", "(3,c)"}, res.Strings())
+}
+
+func TestIPC_empty(t *testing.T) {
+	types := []ep.Type{ep.SetAlias(int64Type, "id")}
+
+	var buf bytes.Buffer
+	_, err := eptest.Run(eparrow.WriteIPC(&buf, types))
+	require.NoError(t, err)
+
+	reader, err := eparrow.ReadIPC(context.Background(), &buf)
+	require.NoError(t, err)
+	require.Equal(t, "id", ep.GetAlias(reader.Returns()[0]))
+	require.Equal(t, int64Type.Name(), reader.Returns()[0].Name())
+
+	schema, err := eparrow.Schema(types)
+	require.NoError(t, err)
+	require.Equal(t, arrow.INT64, schema.Field(0).Type.ID())
+
+	res, err := eptest.Run(reader)
+	require.NoError(t, err)
+	require.Nil(t, res)
+
+	_, err = eparrow.ReadIPC(context.Background(), bytes.NewReader(nil))
+	require.Error(t, err)
+}
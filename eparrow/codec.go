@@ -0,0 +1,63 @@
+package eparrow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/panoplyio/ep"
+)
+
+// CodecName is the name of the ep.Codec that's registered by this package,
+// which encodes the datasets sent by exchanges as Arrow IPC streams of a
+// single record batch, instead of gob:
+//
+//      ctx = ep.WithCodec(ctx, eparrow.CodecName)
+//
+// The datasets are converted like by ToRecord and FromRecord, so their columns
+// are decoded into the types registered to the Go types of their values in the
+// global Types registry, which should be accepted by the receiving runners
+const CodecName = "arrow"
+
+var _ = ep.Codecs.Register(CodecName, &codec{})
+
+type codec struct{}
+
+func (*codec) Encode(data ep.Dataset) ([]byte, error) {
+	types := make([]ep.Type, data.Width())
+	for i := range types {
+		types[i] = data.At(i).Type()
+	}
+
+	rec, err := ToRecord(types, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rec.Release()
+
+	var buf bytes.Buffer
+	w := ipc.NewWriter(&buf, ipc.WithSchema(rec.Schema()))
+	err = w.Write(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	return buf.Bytes(), err
+}
+
+func (*codec) Decode(b []byte) (ep.Dataset, error) {
+	r, err := ipc.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Release()
+
+	if !r.Next() {
+		if r.Err() != nil {
+			return nil, r.Err()
+		}
+		return nil, fmt.Errorf("eparrow: expected a record batch")
+	}
+	return FromRecord(context.Background(), r.Record())
+}
@@ -0,0 +1,45 @@
+package eparrow_test
+
+import (
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eparrow"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	codec := ep.Codecs.Get(eparrow.CodecName)
+	require.NotNil(t, codec)
+
+	record := ep.NewDataset(newValues(stringType, "x", "y"))
+	record.MarkNull(1)
+	data := ep.NewDataset(newValues(int64Type, int64(1), nil), record)
+
+	b, err := codec.Encode(data)
+	require.NoError(t, err)
+	res, err := codec.Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, data.Strings(), res.Strings())
+	require.True(t, res.At(0).IsNull(1))
+	require.True(t, res.At(1).IsNull(1))
+
+	_, err = codec.Decode([]byte("invalid"))
+	require.Error(t, err)
+}
+
+func TestCodec_distributed(t *testing.T) {
+	// ports of this package, as packages are tested concurrently
+	master, peer := eptest.NewPeer(t, ":5561"), eptest.NewPeer(t, ":5562")
+	defer eptest.ClosePeer(t, master)
+	defer eptest.ClosePeer(t, peer)
+
+	runner := master.Distribute(ep.Pipeline(ep.Scatter(), ep.Gather()), ":5561", ":5562")
+	ctx := ep.WithCodec(context.Background(), eparrow.CodecName)
+	data1 := ep.NewDataset(newValues(int64Type, int64(1)), newValues(stringType, "a"))
+	data2 := ep.NewDataset(newValues(int64Type, int64(2)), newValues(stringType, nil))
+	res, err := eptest.RunWithContext(ctx, runner, data1, data2)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"(1,a)", "(2,)"}, res.Strings())
+}
@@ -0,0 +1,121 @@
+package eparrow_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/compare"
+	"reflect"
+	"time"
+)
+
+var _ = ep.Types.
+	Register("int64", int64Type).
+	Register("float64", float64Type).
+	Register("bool", boolType).
+	Register("string", stringType).
+	Register("[]uint8", bytesType).
+	Register("time.Time", timeType)
+
+var int64Type = &valueType{"int64", reflect.TypeOf(int64(0))}
+var float64Type = &valueType{"float64", reflect.TypeOf(float64(0))}
+var boolType = &valueType{"bool", reflect.TypeOf(false)}
+var stringType = &valueType{"string", reflect.TypeOf("")}
+var bytesType = &valueType{"bytes", reflect.TypeOf([]byte(nil))}
+var timeType = &valueType{"time", reflect.TypeOf(time.Time{})}
+
+// valueType is a type of driver.Values of a single Go type, where nulls are
+// nils
+type valueType struct {
+	name     string
+	scanType reflect.Type
+}
+
+func (t *valueType) String() string          { return t.Name() }
+func (t *valueType) Name() string            { return t.name }
+func (*valueType) Size() uint                { return 8 }
+func (t *valueType) ScanType() reflect.Type  { return t.scanType }
+func (t *valueType) Data(n int) ep.Data      { return &values{t, make([]driver.Value, n)} }
+func (t *valueType) Builder() ep.DataBuilder { return &valuesBuilder{&values{T: t}} }
+
+type valuesBuilder struct{ res *values }
+
+func (b *valuesBuilder) Append(data ep.Data) { b.res.Vs = append(b.res.Vs, data.(*values).Vs...) }
+func (b *valuesBuilder) Data() ep.Data       { return b.res }
+
+type values struct {
+	T  *valueType
+	Vs []driver.Value
+}
+
+func (vs *values) Type() ep.Type               { return vs.T }
+func (vs *values) Len() int                    { return len(vs.Vs) }
+func (vs *values) Less(i, j int) bool          { return fmt.Sprint(vs.Vs[i]) < fmt.Sprint(vs.Vs[j]) }
+func (vs *values) Swap(i, j int)               { vs.Vs[i], vs.Vs[j] = vs.Vs[j], vs.Vs[i] }
+func (vs *values) Slice(s, e int) ep.Data      { return &values{vs.T, vs.Vs[s:e]} }
+func (vs *values) IsNull(i int) bool           { return vs.Vs[i] == nil }
+func (vs *values) MarkNull(i int)              { vs.Vs[i] = nil }
+func (vs *values) Equal(other ep.Data) bool    { return vs == other }
+func (vs *values) Value(row int) driver.Value  { return vs.Vs[row] }
+func (vs *values) Copy(from ep.Data, i, j int) { vs.Vs[j] = from.(*values).Vs[i] }
+func (vs *values) Scan(row int, v interface{}) error {
+	if reflect.TypeOf(v) != vs.T.scanType {
+		return fmt.Errorf("expected %s but got %T", vs.T.scanType, v)
+	}
+	vs.Vs[row] = v
+	return nil
+}
+func (vs *values) LessOther(thisRow int, other ep.Data, otherRow int) bool {
+	return fmt.Sprint(vs.Vs[thisRow]) < fmt.Sprint(other.(*values).Vs[otherRow])
+}
+func (vs *values) Duplicate(t int) ep.Data {
+	res := &values{T: vs.T}
+	for i := 0; i < t; i++ {
+		res.Vs = append(res.Vs, vs.Vs...)
+	}
+	return res
+}
+func (vs *values) Nulls() []bool {
+	res := make([]bool, vs.Len())
+	for i := range res {
+		res[i] = vs.IsNull(i)
+	}
+	return res
+}
+func (vs *values) Compare(other ep.Data) ([]compare.Result, error) {
+	res := make([]compare.Result, vs.Len())
+	for i := range res {
+		a, b := fmt.Sprint(vs.Vs[i]), fmt.Sprint(other.(*values).Vs[i])
+		switch {
+		case a == b:
+			res[i] = compare.Equal
+		case a > b:
+			res[i] = compare.Greater
+		default:
+			res[i] = compare.Less
+		}
+	}
+	return res, nil
+}
+func (vs *values) CopyNTimes(from ep.Data, fromRow, toRow int, duplications []int) {
+	for i, n := range duplications {
+		for j := 0; j < n; j++ {
+			vs.Vs[toRow+j] = from.(*values).Vs[fromRow+i]
+		}
+		toRow += n
+	}
+}
+func (vs *values) CopyByIndexes(from ep.Data, fromRows []int, toRow int) {
+	for i, idx := range fromRows {
+		vs.Vs[toRow+i] = from.(*values).Vs[idx]
+	}
+}
+func (vs *values) Strings() []string {
+	res := make([]string, vs.Len())
+	for i, v := range vs.Vs {
+		if v != nil {
+			res[i] = fmt.Sprint(v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,98 @@
+package eparrow
+
+import (
+	"context"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/panoplyio/ep"
+	"io"
+)
+
+// ReadIPC returns a new producing Runner that reads the record batches of the
+// Arrow IPC stream, and emits them as datasets, see FromRecord. The schema of
+// the stream is read immediately, in order to declare the returned types, see
+// Types. The stream reader is released when the first run completes, so the
+// Runner can't be run again
+func ReadIPC(ctx context.Context, r io.Reader) (ep.Runner, error) {
+	reader, err := ipc.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := Types(ctx, reader.Schema())
+	if err != nil {
+		reader.Release()
+		return nil, err
+	}
+	return &ipcReader{reader, types}, nil
+}
+
+type ipcReader struct {
+	Reader *ipc.Reader
+	Types  []ep.Type
+}
+
+func (r *ipcReader) Equals(other interface{}) bool { return r == other }
+func (r *ipcReader) Returns() []ep.Type            { return r.Types }
+func (r *ipcReader) Run(ctx context.Context, inp, out chan ep.Dataset) error {
+	defer r.Reader.Release()
+	for r.Reader.Next() {
+		data, err := FromRecord(ctx, r.Reader.Record())
+		if err != nil {
+			return err
+		} else if data.Len() == 0 {
+			continue // empty datasets are never sent
+		}
+
+		select {
+		case out <- data:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return r.Reader.Err()
+}
+
+// WriteIPC returns a new terminal Runner that writes its input to the writer
+// as an Arrow IPC stream of record batches, whose fields are named by the
+// types, see ToRecord. The schema of the stream is determined by the first
+// batch, or by Schema if there's no input. It doesn't produce any output.
+// Every run writes a complete stream that ends once its input is closed, so
+// multiple runs write consecutive streams to the same writer
+func WriteIPC(w io.Writer, types []ep.Type) ep.Runner {
+	return &ipcWriter{w, types}
+}
+
+type ipcWriter struct {
+	Writer io.Writer
+	Types  []ep.Type
+}
+
+func (r *ipcWriter) Equals(other interface{}) bool { return r == other }
+func (*ipcWriter) Returns() []ep.Type              { return []ep.Type{} }
+func (r *ipcWriter) Run(ctx context.Context, inp, out chan ep.Dataset) error {
+	var w *ipc.Writer
+	for data := range inp {
+		rec, err := ToRecord(r.Types, data)
+		if err != nil {
+			return err
+		}
+
+		if w == nil {
+			w = ipc.NewWriter(r.Writer, ipc.WithSchema(rec.Schema()))
+		}
+		err = w.Write(rec)
+		rec.Release()
+		if err != nil {
+			return err
+		}
+	}
+
+	if w == nil {
+		schema, err := Schema(r.Types)
+		if err != nil {
+			return err
+		}
+		w = ipc.NewWriter(r.Writer, ipc.WithSchema(schema))
+	}
+	return w.Close()
+}
@@ -11,11 +11,12 @@ import (
 	"testing"
 )
 
-// NewPeer returns distributer that listens on the given port
-func NewPeer(t *testing.T, port string) ep.Distributer {
+// NewPeer returns distributer that listens on the given port, configured with
+// the given options
+func NewPeer(t *testing.T, port string, opts ...ep.DistributerOption) ep.Distributer {
 	ln, err := net.Listen("tcp", port)
 	require.NoError(t, err)
-	return ep.NewDistributer(port, ln)
+	return ep.NewDistributer(port, ln, opts...)
 }
 
 // ClosePeer closes all given distributers
@@ -0,0 +1,88 @@
+package eptest
+
+import (
+	"fmt"
+	"github.com/panoplyio/ep"
+	"sync"
+)
+
+// Tracer is an ep.Tracer that records all spans in memory, for tests that
+// verify the traces of runners. It's safe for concurrent use, and a single
+// instance can be shared by all nodes of a test cluster
+type Tracer struct {
+	l     sync.Mutex
+	spans []*Span
+}
+
+// Span is a span recorded by Tracer
+type Span struct {
+	Name       string
+	Parent     ep.SpanContext
+	Attributes map[string]string
+	Err        error
+	Ended      bool
+
+	t  *Tracer
+	sc ep.SpanContext
+}
+
+// NewTracer returns a new in-memory Tracer
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// StartSpan implements ep.Tracer
+func (t *Tracer) StartSpan(name string, parent ep.SpanContext) ep.Span {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	traceID := parent.TraceID
+	if traceID == "" {
+		traceID = fmt.Sprintf("trace-%d", len(t.spans))
+	}
+
+	span := &Span{
+		Name:       name,
+		Parent:     parent,
+		Attributes: map[string]string{},
+		t:          t,
+		sc:         ep.SpanContext{TraceID: traceID, SpanID: fmt.Sprintf("span-%d", len(t.spans))},
+	}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+// Spans returns copies of all of the recorded spans, in the order they were
+// started
+func (t *Tracer) Spans() []Span {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	res := make([]Span, len(t.spans))
+	for i, span := range t.spans {
+		res[i] = *span
+		res[i].Attributes = make(map[string]string, len(span.Attributes))
+		for k, v := range span.Attributes {
+			res[i].Attributes[k] = v
+		}
+	}
+	return res
+}
+
+// Context implements ep.Span
+func (s *Span) Context() ep.SpanContext { return s.sc }
+
+// SetAttribute implements ep.Span
+func (s *Span) SetAttribute(key, value string) {
+	s.t.l.Lock()
+	defer s.t.l.Unlock()
+	s.Attributes[key] = value
+}
+
+// End implements ep.Span
+func (s *Span) End(err error) {
+	s.t.l.Lock()
+	defer s.t.l.Unlock()
+	s.Err = err
+	s.Ended = true
+}
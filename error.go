@@ -0,0 +1,131 @@
+package ep
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var _ = registerGob(&RemoteError{})
+
+// Errors registry. Maps codes to errors that should be recognizable across
+// nodes. When a Runner fails on a peer, its error is matched against the
+// registered errors using errors.Is, and only the matching code is transmitted
+// back to the master node, where it's decoded back into the registered error.
+// Typed errors can be registered as well, given that they implement an Is
+// method that matches their registered instance.
+//
+//      ep.Errors.Register("not_found", ErrNotFound)
+var Errors = (&errorsReg{errs: make(map[string]error)}).Register("ignorable", ErrIgnorable)
+
+// registry of errors. It's safe for concurrent use, as it's read by peers
+// while runners are served
+type errorsReg struct {
+	l     sync.RWMutex
+	codes []string // in the order of their registration, see Code
+	errs  map[string]error
+}
+
+// Register a code-error pair to be globally accessible via the Get() function
+// using the same code.
+func (reg *errorsReg) Register(code string, err error) *errorsReg {
+	reg.l.Lock()
+	defer reg.l.Unlock()
+	if _, ok := reg.errs[code]; !ok {
+		reg.codes = append(reg.codes, code)
+	}
+	reg.errs[code] = err
+	return reg
+}
+
+// Get the error that was previously registered to the provided code via the
+// Register() function, or nil if that code is unknown.
+func (reg *errorsReg) Get(code string) error {
+	reg.l.RLock()
+	defer reg.l.RUnlock()
+	return reg.errs[code]
+}
+
+// Code returns the code of the first registered error that matches err, or an
+// empty string if none of the registered errors matches it
+func (reg *errorsReg) Code(err error) string {
+	reg.l.RLock()
+	defer reg.l.RUnlock()
+	for _, code := range reg.codes {
+		if errors.Is(err, reg.errs[code]) {
+			return code
+		}
+	}
+	return ""
+}
+
+// RemoteError is an error produced by a Runner running on a remote node,
+// transmitted back to the master node that distributed it. Infrastructure
+// failures (dialing, encoding, etc.) are never wrapped by RemoteError, which
+// allows clients to tell them apart from errors produced by runners, using
+// errors.As. The original error is decodable on the master via errors.Is only if
+// it was registered in the Errors registry, see Errors.
+type RemoteError struct {
+	Node string // address of the node where the error occurred
+	Path string // path of the failing runner in the distributed runner
+	Code string // registry code of the original error, see Errors
+	Msg  string // message of the original error
+}
+
+// NewRemoteError returns a RemoteError that describes err as it occurred on
+// the provided node
+func NewRemoteError(node string, err error) *RemoteError {
+	if remoteErr, ok := err.(*RemoteError); ok {
+		return remoteErr // already transmitted from another node, keep its origin
+	}
+
+	return &RemoteError{
+		Node: node,
+		Path: runnerPath(err),
+		Code: Errors.Code(err),
+		Msg:  err.Error(),
+	}
+}
+
+// Error implements error. The message is identical to the original one, in
+// order to keep errors transparent regardless of where they occurred
+func (err *RemoteError) Error() string { return err.Msg }
+
+// Unwrap returns the registered error that corresponds to the code of this
+// error, or nil if it's unknown
+func (err *RemoteError) Unwrap() error { return Errors.Get(err.Code) }
+
+// runnerError annotates an error with the position of the failing runner
+// within its composite runner, without modifying its message
+type runnerError struct {
+	err  error
+	step string
+}
+
+// atRunner wraps err with the position of the i-th runner within a composite
+// runner with the given name. As the error is wrapped, callers should match
+// it with errors.Is rather than by identity. ErrIgnorable and errOnPeer are
+// left as-is, as they're internal signals rather than failures of the runner
+func atRunner(err error, name string, i int) error {
+	if err == nil || errors.Is(err, ErrIgnorable) || errors.Is(err, errOnPeer) {
+		return err
+	}
+	return &runnerError{err, fmt.Sprintf("%s[%d]", name, i)}
+}
+
+func (err *runnerError) Error() string { return err.err.Error() }
+func (err *runnerError) Unwrap() error { return err.err }
+
+// runnerPath returns the path of the failing runner as annotated by atRunner,
+// e.g. "pipeline[2]/project[0]"
+func runnerPath(err error) string {
+	var steps []string
+	for err != nil {
+		if re, ok := err.(*runnerError); ok {
+			steps = append(steps, re.step)
+		}
+		err = errors.Unwrap(err)
+	}
+	return strings.Join(steps, "/")
+}
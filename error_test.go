@@ -0,0 +1,89 @@
+package ep
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var errRegistered = errors.New("registered")
+var _ = Errors.Register("registered", errRegistered)
+
+func TestNewRemoteError(t *testing.T) {
+	err := fmt.Errorf("failed: %w", errRegistered)
+	err = atRunner(atRunner(err, "project", 1), "pipeline", 2)
+
+	remoteErr := NewRemoteError(":5552", err)
+	require.Equal(t, &RemoteError{
+		Node: ":5552",
+		Path: "pipeline[2]/project[1]",
+		Code: "registered",
+		Msg:  "failed: registered",
+	}, remoteErr)
+
+	// already remote errors keep their origin
+	require.Equal(t, remoteErr, NewRemoteError(":5553", remoteErr))
+}
+
+func TestRemoteError_gob(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+	}{
+		{name: "registered", err: fmt.Errorf("failed: %w", errRegistered), target: errRegistered},
+		{name: "ignorable", err: ErrIgnorable, target: ErrIgnorable},
+		{name: "unregistered", err: errors.New("unknown"), target: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(&req{NewRemoteError(":5552", tc.err)})
+			require.NoError(t, err)
+
+			res := &req{}
+			err = gob.NewDecoder(&buf).Decode(res)
+			require.NoError(t, err)
+
+			err = res.Payload.(error)
+			require.Equal(t, tc.err.Error(), err.Error())
+
+			var remoteErr *RemoteError
+			require.True(t, errors.As(err, &remoteErr))
+			require.Equal(t, ":5552", remoteErr.Node)
+			if tc.target != nil {
+				require.True(t, errors.Is(err, tc.target))
+			} else {
+				require.Nil(t, errors.Unwrap(err))
+			}
+		})
+	}
+}
+
+func TestAtRunner_keepIdentityErrors(t *testing.T) {
+	require.Equal(t, ErrIgnorable, atRunner(ErrIgnorable, "pipeline", 0))
+	require.Equal(t, errOnPeer, atRunner(errOnPeer, "pipeline", 0))
+	require.Nil(t, atRunner(nil, "pipeline", 0))
+}
+
+func TestErrorsReg_Code(t *testing.T) {
+	errFirst := fmt.Errorf("first: %w", errRegistered)
+	errBoth := fmt.Errorf("failed: %w", errFirst) // matches both errors
+
+	// the first registered error that matches wins, regardless of map order
+	reg := (&errorsReg{errs: make(map[string]error)}).
+		Register("first", errFirst).
+		Register("registered", errRegistered)
+	for i := 0; i < 100; i++ {
+		require.Equal(t, "first", reg.Code(errBoth))
+	}
+
+	// re-registering a code keeps its position
+	reg.Register("first", errors.New("other"))
+	require.Equal(t, "registered", reg.Code(errBoth))
+	require.Equal(t, "", reg.Code(errors.New("unknown")))
+}
@@ -103,7 +103,8 @@ func (ex *exchange) Equals(other interface{}) bool {
 	return true
 }
 
-func (ex *exchange) Returns() []Type { return []Type{Wildcard} }
+func (ex *exchange) Returns() []Type  { return []Type{Wildcard} }
+func (ex *exchange) Idempotent() bool { return true }
 func (ex *exchange) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 	defer func() {
 		closeErr := ex.Close()
@@ -135,7 +136,7 @@ func (ex *exchange) Run(ctx context.Context, inp, out chan Dataset) (err error)
 
 			msg := errorMsg
 			errOnCtx := getError(ctx)
-			if errOnCtx == ErrIgnorable {
+			if errors.Is(errOnCtx, ErrIgnorable) {
 				msg = eofMsg
 			}
 			encodeErr := ex.notifyTermination(ctx, msg)
@@ -160,7 +161,7 @@ func (ex *exchange) Run(ctx context.Context, inp, out chan Dataset) (err error)
 		// send data
 		case data, open := <-inp:
 			err = getError(ctx)
-			if err != nil && err != ErrIgnorable {
+			if err != nil && !errors.Is(err, ErrIgnorable) {
 				return nil
 			}
 			if !open {
@@ -213,6 +214,7 @@ func (ex *exchange) init(ctx context.Context) error {
 	allNodes, _ := ctx.Value(allNodesKey).([]string)
 	thisNode, _ := ctx.Value(thisNodeKey).(string)
 	masterNode, _ := ctx.Value(masterNodeKey).(string)
+	execID, _ := ctx.Value(execIDKey).(string)
 	dist, _ := ctx.Value(distributerKey).(interface {
 		Connect(addr, uid string) (net.Conn, error)
 	})
@@ -223,8 +225,16 @@ func (ex *exchange) init(ctx context.Context) error {
 		allNodes = []string{thisNode}
 	}
 
+	// connections are keyed by both the execution and exchange, to allow
+	// re-dispatching the same exchange in a new execution
+	key := ex.UID
+	if execID != "" {
+		key = execID + "/" + ex.UID
+	}
+
 	ex.encsByKey = make(map[string]encoder)
 	ex.hashRing = consistent.New()
+	codec := codecName(ctx)
 
 	// open a connection to all nodes
 	connsMap := make(map[string]net.Conn, len(allNodes))
@@ -241,14 +251,18 @@ func (ex *exchange) init(ctx context.Context) error {
 			continue
 		}
 
-		conn, err := dist.Connect(node, ex.UID)
+		conn, err := dist.Connect(node, key)
 		if err != nil {
 			return err
 		}
 
+		conn = countBytes(ctx, conn)
 		connsMap[node] = conn
 		ex.conns = append(ex.conns, conn)
-		enc := gob.NewEncoder(conn)
+		var enc encoder = gob.NewEncoder(conn)
+		if codec != "" {
+			enc = &codecEncoder{enc, codec} // see WithCodec
+		}
 		ex.encs = append(ex.encs, enc)
 		ex.hashRing.Add(node)
 		ex.encsByKey[node] = enc
@@ -264,11 +278,12 @@ func (ex *exchange) init(ctx context.Context) error {
 			continue
 		}
 
-		conn, err := dist.Connect(node, ex.UID)
+		conn, err := dist.Connect(node, key)
 		if err != nil {
 			return err
 		}
 
+		conn = countBytes(ctx, conn)
 		connsMap[node] = conn
 		ex.conns = append(ex.conns, conn)
 		enc := gob.NewEncoder(conn)
@@ -453,7 +468,7 @@ func decode(d decoder) (Dataset, error) {
 	if err != nil {
 		return nil, err
 	}
-	return req.Payload.(Dataset), nil
+	return decodeDataset(req.Payload)
 }
 
 // interfaces for gob.Encoder/Decoder. Used to also implement the short-circuit.
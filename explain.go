@@ -0,0 +1,287 @@
+package ep
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Explainer is a Runner that describes itself in the output of Explain. By
+// default, runners are described by their type name
+type Explainer interface {
+	Runner // it's a Runner
+
+	// Explain returns a short single-line description of the Runner
+	Explain() string
+}
+
+// PlanNode is a single runner within the tree rendered by Explain
+type PlanNode struct {
+	Name       string      `json:"name"`
+	Path       string      `json:"path,omitempty"` // see Stats
+	Returns    []string    `json:"returns"`
+	ApproxSize int         `json:"approxSize"`
+	Rows       int         `json:"rows,omitempty"` // see CostModel
+	Cost       float64     `json:"cost,omitempty"` // see CostModel
+	Children   []*PlanNode `json:"children,omitempty"`
+
+	// wrapping runners, and the components of Compose, share the path of
+	// their parent as they aren't executed as separate runners
+	sharedPath bool
+}
+
+// Explain renders the runner as a human-readable indented tree. Each runner
+// is displayed along with its return types, including their aliases and
+// scopes, its approximate size, and its estimated number of rows and cost by
+// DefaultCostModel when they're known:
+//
+//      pipeline [upper:str]
+//        scatter [*]
+//        upper [upper:str]
+//        gather [*]
+func Explain(r Runner) string {
+	return explainPlan(r).String()
+}
+
+// ExplainDOT renders the runner as a graph in the DOT language, see Explain
+func ExplainDOT(r Runner) string {
+	var b strings.Builder
+	b.WriteString("digraph plan {\n")
+	b.WriteString("  node [shape=box];\n")
+	explainPlan(r).writeDOT(&b, new(int))
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ExplainJSON renders the runner as a JSON tree of PlanNodes, see Explain
+func ExplainJSON(r Runner) string {
+	res, err := json.MarshalIndent(explainPlan(r), "", "  ")
+	if err != nil {
+		panic(err) // PlanNodes are always encodable
+	}
+	return string(res)
+}
+
+// ExplainAnalyze renders the runner like Explain, where each runner is also
+// annotated with the execution metrics collected while running it with a
+// context created by WithStats:
+//
+//      ctx = ep.WithStats(ctx)
+//      err := runner.Run(ctx, inp, out)
+//      fmt.Println(ep.ExplainAnalyze(runner, ep.GetStats(ctx)))
+func ExplainAnalyze(r Runner, stats *Stats) string {
+	var b strings.Builder
+	explainPlan(r).write(&b, 0, func(n *PlanNode) string {
+		if rs := stats.Get(n.Path); rs != nil && !n.sharedPath {
+			return " (" + rs.String() + ")"
+		}
+		return ""
+	})
+	return b.String()
+}
+
+func (n *PlanNode) String() string {
+	var b strings.Builder
+	n.write(&b, 0, func(*PlanNode) string { return "" })
+	return b.String()
+}
+
+func (n *PlanNode) write(b *strings.Builder, depth int, annotate func(*PlanNode) string) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(n.Name)
+	b.WriteString(" [" + strings.Join(n.Returns, ", ") + "]")
+	if n.ApproxSize != UnknownSize {
+		b.WriteString(fmt.Sprintf(" size=%d", n.ApproxSize))
+	}
+	if n.Cost > 0 {
+		b.WriteString(fmt.Sprintf(" rows=%d cost=%.0f", n.Rows, n.Cost))
+	}
+	b.WriteString(annotate(n))
+	b.WriteString("\n")
+
+	for _, child := range n.Children {
+		child.write(b, depth+1, annotate)
+	}
+}
+
+func (n *PlanNode) writeDOT(b *strings.Builder, nextID *int) int {
+	id := *nextID
+	*nextID++
+
+	label := n.Name + `\n[` + strings.Join(n.Returns, ", ") + "]"
+	if n.ApproxSize != UnknownSize {
+		label += fmt.Sprintf(`\nsize=%d`, n.ApproxSize)
+	}
+	if n.Cost > 0 {
+		label += fmt.Sprintf(`\nrows=%d cost=%.0f`, n.Rows, n.Cost)
+	}
+	b.WriteString(fmt.Sprintf("  n%d [label=\"%s\"];\n", id, escapeDOT(label)))
+
+	for _, child := range n.Children {
+		childID := child.writeDOT(b, nextID)
+		b.WriteString(fmt.Sprintf("  n%d -> n%d;\n", id, childID))
+	}
+	return id
+}
+
+// escapeDOT escapes quotes within a DOT label, while keeping its line breaks
+func escapeDOT(label string) string {
+	return strings.Replace(label, `"`, `\"`, -1)
+}
+
+// explainPlan builds the tree of PlanNodes of the runner
+func explainPlan(r Runner) *PlanNode {
+	estimates := make(map[string]Estimate)
+	m := DefaultCostModel
+	m.estimate(r, Estimate{Rows: UnknownSize}, 1, "", func(path string, e Estimate) {
+		estimates[path] = e
+	})
+
+	node := newPlanNode(r, "", false)
+	node.setEstimates(estimates)
+	return node
+}
+
+func (n *PlanNode) setEstimates(estimates map[string]Estimate) {
+	if e, ok := estimates[n.Path]; ok && e.Known && !n.sharedPath {
+		n.Rows, n.Cost = e.Rows, e.Cost
+	}
+	for _, child := range n.Children {
+		child.setEstimates(estimates)
+	}
+}
+
+func newPlanNode(r Runner, path string, sharedPath bool) *PlanNode {
+	node := &PlanNode{
+		Name:       explainName(r),
+		Path:       path,
+		Returns:    explainTypes(r.Returns()),
+		ApproxSize: UnknownSize,
+		sharedPath: sharedPath,
+	}
+
+	if sizer, ok := r.(ApproxSizer); ok {
+		node.ApproxSize = sizer.ApproxSize()
+	}
+
+	if c, ok := r.(*compose); ok {
+		for _, cmp := range c.Cmps {
+			node.Children = append(node.Children, newComposableNode(cmp, path))
+		}
+		return node
+	}
+
+	for _, child := range runnerChildren(r) {
+		childPath := path
+		if child.step != "" && path != "" {
+			childPath = path + "/" + child.step
+		} else if child.step != "" {
+			childPath = child.step
+		}
+		node.Children = append(node.Children, newPlanNode(child.runner, childPath, child.step == ""))
+	}
+	return node
+}
+
+func newComposableNode(cmp Composable, path string) *PlanNode {
+	if r, ok := cmp.(Runner); ok {
+		return newPlanNode(r, path, true)
+	}
+
+	name := strings.TrimPrefix(fmt.Sprintf("%T", cmp), "*")
+	if explainer, ok := cmp.(interface{ Explain() string }); ok {
+		name = explainer.Explain()
+	}
+	return &PlanNode{
+		Name:       name,
+		Path:       path,
+		Returns:    explainTypes(cmp.Returns()),
+		ApproxSize: UnknownSize,
+		sharedPath: true,
+	}
+}
+
+// explainName returns the description of the runner, including the details
+// of the built-in runners
+func explainName(r Runner) string {
+	if explainer, ok := r.(Explainer); ok {
+		return explainer.Explain()
+	}
+
+	switch r := r.(type) {
+	case *exchange:
+		switch r.Type {
+		case partition:
+			return fmt.Sprintf("partition(%s)", joinInts(r.PartitionCols))
+		case sortGather:
+			cols := make([]string, len(r.SortingCols))
+			for i, col := range r.SortingCols {
+				cols[i] = fmt.Sprint(col.Index)
+				if col.Desc {
+					cols[i] += " desc"
+				}
+			}
+			return fmt.Sprintf("sortGather(%s)", strings.Join(cols, ", "))
+		}
+	case *distRunner:
+		return fmt.Sprintf("distribute(%s)", strings.Join(r.Addrs, ", "))
+	}
+	return runnerName(r)
+}
+
+// explainTypes describes the types, including their aliases and scopes, like
+// "scope.alias:type"
+func explainTypes(types []Type) []string {
+	res := make([]string, len(types))
+	for i, t := range types {
+		res[i] = t.String()
+		if alias := GetAlias(t); alias != "" {
+			res[i] = alias + ":" + res[i]
+		}
+		if scope := GetScope(t); scope != "" {
+			res[i] = scope + "." + res[i]
+		}
+	}
+	return res
+}
+
+func joinInts(ints []int) string {
+	res := make([]string, len(ints))
+	for i, v := range ints {
+		res[i] = fmt.Sprint(v)
+	}
+	return strings.Join(res, ", ")
+}
+
+// childRunner is a runner within a composite runner, along with its step in
+// the path of the runner, see Stats
+type childRunner struct {
+	step   string
+	runner Runner
+}
+
+// runnerChildren returns the inner runners of the composite runner
+func runnerChildren(r Runner) []childRunner {
+	var name string
+	var rs []Runner
+	switch r := r.(type) {
+	case pipeline:
+		name, rs = "pipeline", r
+	case project:
+		name, rs = "project", r
+	case union:
+		name, rs = "union", r
+	case *distRunner:
+		return []childRunner{{"distribute", r.Runner}}
+	case *alias:
+		return []childRunner{{"", r.Runner}}
+	case *scope:
+		return []childRunner{{"", r.Runner}}
+	}
+
+	children := make([]childRunner, len(rs))
+	for i, r := range rs {
+		children[i] = childRunner{fmt.Sprintf("%s[%d]", name, i), r}
+	}
+	return children
+}
@@ -0,0 +1,63 @@
+package ep_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/panoplyio/ep"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func ExampleExplain() {
+	runner := ep.Pipeline(
+		ep.Partition(0),
+		ep.Project(ep.Scope(&upper{}, "u"), ep.PassThrough()),
+		ep.SortGather([]ep.SortingCol{{Index: 0, Desc: true}}),
+	)
+	fmt.Println(ep.Explain(runner))
+
+	// Output:
+	// pipeline [u.upper:string, *]
+	//   partition(0) [*]
+	//   project [u.upper:string, *]
+	//     scope(u) [u.upper:string]
+	//       ep_test.upper [upper:string]
+	//     passThrough [*]
+	//   sortGather(0 desc) [*]
+}
+
+type describedRunner struct{ upper }
+
+func (*describedRunner) Explain() string { return "described(42)" }
+
+func TestExplain_explainer(t *testing.T) {
+	runner := ep.Pipeline(&describedRunner{}, ep.Compose(nil, &addInts{}))
+	lines := strings.Split(ep.Explain(runner), "\n")
+	require.Equal(t, "  described(42) [upper:string]", lines[1])
+	require.Equal(t, "  compose [integer]", lines[2])
+	require.Equal(t, "    ep_test.addInts [integer]", lines[3])
+}
+
+func TestExplainDOT(t *testing.T) {
+	runner := ep.Pipeline(&upper{}, ep.Gather())
+	dot := ep.ExplainDOT(runner)
+	require.True(t, strings.HasPrefix(dot, "digraph plan {\n"), dot)
+	require.Contains(t, dot, `n1 [label="ep_test.upper\n[upper:string]"];`)
+	require.Contains(t, dot, "n0 -> n1;")
+	require.Contains(t, dot, "n0 -> n2;")
+}
+
+func TestExplainJSON(t *testing.T) {
+	runner := ep.Pipeline(&upper{}, ep.Gather())
+
+	var node ep.PlanNode
+	err := json.Unmarshal([]byte(ep.ExplainJSON(runner)), &node)
+	require.NoError(t, err)
+	require.Equal(t, "pipeline", node.Name)
+	require.Equal(t, ep.UnknownSize, node.ApproxSize)
+	require.Equal(t, 2, len(node.Children))
+	require.Equal(t, "pipeline[0]", node.Children[0].Path)
+	require.Equal(t, []string{"upper:string"}, node.Children[0].Returns)
+	require.Equal(t, "gather", node.Children[1].Name)
+}
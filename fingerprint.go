@@ -0,0 +1,153 @@
+package ep
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+)
+
+// Fingerprinter is a Runner that also computes its own fingerprint, see
+// Fingerprint. Runners that are configured by their fields should implement
+// it in order to tell apart runners of the same type, by hashing the same
+// fields that are compared by their Equals
+type Fingerprinter interface {
+	Runner // it's a Runner
+
+	// Fingerprint returns a hash of the Runner, such that equal Runners
+	// always have equal fingerprints
+	Fingerprint() uint64
+}
+
+// Fingerprint returns a structural hash of the runner tree, consistent with
+// Equals: runners that are equal always have the same fingerprint, regardless
+// of the properties ignored by Equals, like exchange UIDs, Batch sizes and
+// labels of aliases and scopes. Unequal runners usually have different
+// fingerprints, but may collide, so the fingerprint is used for looking up
+// candidates that are then compared with Equals. Runners that don't implement
+// Fingerprinter are hashed by their type alone
+func Fingerprint(r Runner) uint64 {
+	h := fnv.New64a()
+	fingerprint(h, r)
+	return h.Sum64()
+}
+
+func fingerprint(h hash.Hash64, r Runner) {
+	switch r := r.(type) {
+	case pipeline:
+		fingerprintRunners(h, "pipeline", r)
+	case project:
+		fingerprintRunners(h, "project", r)
+	case union:
+		fingerprintRunners(h, "union", r)
+	case *alias:
+		writeString(h, "alias")
+		fingerprint(h, r.Runner)
+	case *scope:
+		writeString(h, "scope")
+		fingerprint(h, r.Runner)
+	case *rows:
+		writeString(h, "rows")
+		fingerprint(h, r.Runner)
+	case *distRunner:
+		writeString(h, "distribute")
+		writeString(h, r.MasterAddr)
+		writeInt(h, int(r.PeerOutput))
+		fingerprint(h, r.Runner)
+	case *compose:
+		writeString(h, "compose")
+		writeInt(h, len(r.Cmps))
+		for _, cmp := range r.Cmps {
+			fingerprintComposable(h, cmp)
+		}
+	case *exchange:
+		writeString(h, "exchange")
+		writeInt(h, int(r.Type))
+		writeInt(h, len(r.SortingCols))
+		for _, col := range r.SortingCols {
+			writeInt(h, col.Index)
+			writeBool(h, col.Desc)
+		}
+		writeInt(h, len(r.PartitionCols))
+		for _, col := range r.PartitionCols {
+			writeInt(h, col)
+		}
+	case *pick:
+		writeString(h, "pick")
+		writeInt(h, len(r.Indices))
+		for _, idx := range r.Indices {
+			writeInt(h, idx)
+		}
+	case *passThrough:
+		// types aren't hashed, as AreEqualTypes matches any type to all types
+		writeString(h, "passThrough")
+		writeInt(h, len(r.ReturnTypes))
+	case *tail:
+		writeString(h, "tail")
+		writeInt(h, len(r.Types))
+	case *constt:
+		// data of different types is never equal, see Data.Compare
+		writeString(h, "const")
+		writeString(h, r.Data.Type().String())
+	case *values:
+		writeString(h, "values")
+		writeInt(h, len(r.Types))
+		writeInt(h, r.Data.Len())
+	case Fingerprinter:
+		writeString(h, fmt.Sprintf("%T", r))
+		writeUint64(h, r.Fingerprint())
+	default:
+		writeString(h, fmt.Sprintf("%T", r))
+	}
+}
+
+func fingerprintRunners(h hash.Hash64, name string, rs []Runner) {
+	writeString(h, name)
+	writeInt(h, len(rs))
+	for _, r := range rs {
+		fingerprint(h, r)
+	}
+}
+
+func fingerprintComposable(h hash.Hash64, cmp Composable) {
+	switch cmp := cmp.(type) {
+	case composeProject:
+		writeString(h, "composeProject")
+		writeInt(h, len(cmp))
+		for _, c := range cmp {
+			fingerprintComposable(h, c)
+		}
+	case Runner:
+		fingerprint(h, cmp)
+	case interface{ Fingerprint() uint64 }:
+		writeString(h, fmt.Sprintf("%T", cmp))
+		writeUint64(h, cmp.Fingerprint())
+	default:
+		writeString(h, fmt.Sprintf("%T", cmp))
+	}
+}
+
+// writeString writes the string along with its length, so that consecutive
+// strings can't be confused with each other
+func writeString(h hash.Hash64, s string) {
+	writeInt(h, len(s))
+	h.Write([]byte(s))
+}
+
+func writeInt(h hash.Hash64, v int) {
+	writeUint64(h, uint64(v))
+}
+
+func writeBool(h hash.Hash64, v bool) {
+	if v {
+		writeUint64(h, 1)
+	} else {
+		writeUint64(h, 0)
+	}
+}
+
+func writeUint64(h hash.Hash64, v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	h.Write(b[:])
+}
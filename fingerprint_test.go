@@ -0,0 +1,79 @@
+package ep_test
+
+import (
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// weighted is a runner that's configured by its weight
+type weighted struct{ Weight int }
+
+func (r *weighted) Equals(other interface{}) bool {
+	o, ok := other.(*weighted)
+	return ok && r.Weight == o.Weight
+}
+
+func (*weighted) Returns() []ep.Type    { return []ep.Type{integer} }
+func (r *weighted) Fingerprint() uint64 { return uint64(r.Weight) }
+func (*weighted) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		out <- data
+	}
+	return nil
+}
+
+func TestFingerprint(t *testing.T) {
+	newRunner := func(batchSize int, label string) ep.Runner {
+		return ep.Pipeline(
+			ep.Scatter(),
+			ep.Batch(batchSize),
+			ep.Project(ep.Alias(&upper{}, label), ep.Pick(0)),
+			ep.Gather(),
+		)
+	}
+
+	r1, r2 := newRunner(10, "a"), newRunner(100, "b")
+	require.True(t, r1.Equals(r2))
+	require.Equal(t, ep.Fingerprint(r1), ep.Fingerprint(r2))
+
+	r3 := ep.Pipeline(ep.Scatter(), ep.Project(&upper{}, ep.Pick(1)), ep.Gather())
+	require.False(t, r1.Equals(r3))
+	require.NotEqual(t, ep.Fingerprint(r1), ep.Fingerprint(r3))
+}
+
+func TestFingerprint_distinct(t *testing.T) {
+	runners := []ep.Runner{
+		ep.Pipeline(&upper{}, &question{}),
+		ep.Pipeline(&question{}, &upper{}),
+		ep.Project(&upper{}, &question{}),
+		ep.Gather(),
+		ep.Broadcast(),
+		ep.Partition(0),
+		ep.Partition(1),
+		ep.SortGather([]ep.SortingCol{{Index: 0, Desc: true}}),
+		ep.SortGather([]ep.SortingCol{{Index: 0}}),
+		ep.Pick(0, 1),
+		ep.Pick(1, 0),
+		&weighted{1},
+		&weighted{2},
+	}
+
+	seen := map[uint64]int{}
+	for i, r := range runners {
+		fp := ep.Fingerprint(r)
+		j, ok := seen[fp]
+		require.False(t, ok, "%d collides with %d", i, j)
+		seen[fp] = i
+	}
+}
+
+func TestFingerprint_fingerprinter(t *testing.T) {
+	r1 := ep.Pipeline(&weighted{1}, &upper{})
+	r2 := ep.Pipeline(&weighted{1}, &upper{})
+	require.Equal(t, ep.Fingerprint(r1), ep.Fingerprint(r2))
+
+	r3 := ep.Pipeline(&weighted{2}, &upper{})
+	require.NotEqual(t, ep.Fingerprint(r1), ep.Fingerprint(r3))
+}
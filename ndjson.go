@@ -0,0 +1,325 @@
+package ep
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DefaultNDJSONBatchSize is the maximum number of rows in each of the datasets
+// produced by ReadNDJSON, unless it's configured by its NDJSONOptions
+const DefaultNDJSONBatchSize = 1000
+
+// NDJSONOptions configures ReadNDJSON
+type NDJSONOptions struct {
+	// BatchSize is the maximum number of rows in each dataset, defaults to
+	// DefaultNDJSONBatchSize
+	BatchSize int
+}
+
+// ReadNDJSON returns a new producing Runner that reads newline-delimited JSON
+// objects from the reader, and emits them in batches. The top-level fields of
+// the objects are mapped to the columns of the schema by their aliases (see
+// SetAlias), where missing fields are nulls and unknown fields are ignored.
+// The values are set by the ScannerData of the types, like in FromStructs, as
+// they're decoded by encoding/json (float64, string, bool, etc.).
+//
+// Columns of the Any type are of the concrete type of their values, which is
+// picked from the Types registry of the context by the name of its Go type
+// (see FromStructs) and may vary from batch to batch, where a batch ends early
+// whenever it changes. Nested objects become Record datasets of their fields,
+// in the order of their names, which are typed like Any columns. Columns of the
+// Record type are always typed like nested objects.
+//
+// Every run scans the reader from where the previous run stopped, through a
+// buffer of its own, so a run that's canceled early may consume lines that it
+// never emitted
+func ReadNDJSON(r io.Reader, schema []Type, opts NDJSONOptions) Runner {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultNDJSONBatchSize
+	}
+	return &ndjsonReader{Reader: r, Schema: schema, Opts: opts}
+}
+
+type ndjsonReader struct {
+	Reader io.Reader
+	Schema []Type
+	Opts   NDJSONOptions
+}
+
+func (r *ndjsonReader) Equals(other interface{}) bool { return r == other }
+func (r *ndjsonReader) Returns() []Type               { return r.Schema }
+func (r *ndjsonReader) Run(ctx context.Context, inp, out chan Dataset) error {
+	reg := GetRegistry(ctx).Types
+	var rows [][]interface{}
+	var kinds []string // of the batch, see jsonKind
+	scanner := bufio.NewScanner(r.Reader)
+	scanner.Buffer(nil, 1<<26)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var obj map[string]interface{}
+		err := json.Unmarshal(scanner.Bytes(), &obj)
+		if err != nil {
+			return fmt.Errorf("ep: can't decode NDJSON line %d: %s", line, err)
+		}
+
+		row := make([]interface{}, len(r.Schema))
+		for i, t := range r.Schema {
+			row[i] = obj[GetAlias(t)]
+		}
+
+		rowKinds, compatible := r.kinds(row, kinds)
+		if !compatible || len(rows) == r.Opts.BatchSize {
+			data, err := ndjsonDataset(reg, r.Schema, rows)
+			if err != nil {
+				return err
+			}
+
+			select {
+			case out <- data:
+			case <-ctx.Done():
+				return nil
+			}
+			rows = nil
+			rowKinds, _ = r.kinds(row, nil)
+		}
+		rows, kinds = append(rows, row), rowKinds
+	}
+
+	if err := scanner.Err(); err != nil || len(rows) == 0 {
+		return err
+	}
+
+	data, err := ndjsonDataset(reg, r.Schema, rows)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case out <- data:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// kinds returns the kinds of the values of the inferred columns of the row,
+// merged with the kinds of the batch, or false if they're incompatible
+func (r *ndjsonReader) kinds(row []interface{}, batch []string) ([]string, bool) {
+	res := make([]string, len(row))
+	for i, v := range row {
+		if !isInferred(r.Schema[i]) {
+			continue
+		}
+
+		res[i] = jsonKind(v)
+		if batch != nil && batch[i] != "" {
+			if res[i] != "" && res[i] != batch[i] {
+				return nil, false
+			}
+			res[i] = batch[i]
+		}
+	}
+	return res, true
+}
+
+// isInferred returns true if the type of the column is inferred from its
+// values, see ReadNDJSON
+func isInferred(t Type) bool {
+	return isAny(t) || t.Name() == Record.Name()
+}
+
+// jsonKind returns the name of the Go type of the decoded JSON value, or the
+// names and kinds of the fields of objects. Nulls have no kind, as they're
+// compatible with all kinds
+func jsonKind(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k, field := range v {
+			keys = append(keys, fmt.Sprintf("%q:%s", k, jsonKind(field)))
+		}
+		sort.Strings(keys)
+		return "{" + strings.Join(keys, ",") + "}"
+	}
+	return goTypeKey(reflect.TypeOf(v))
+}
+
+// ndjsonDataset returns the dataset of the rows of decoded JSON values
+func ndjsonDataset(reg typesReg, schema []Type, rows [][]interface{}) (Dataset, error) {
+	res := make([]Data, len(schema))
+	for i, t := range schema {
+		values := make([]interface{}, len(rows))
+		for row := range rows {
+			values[row] = rows[row][i]
+		}
+
+		var err error
+		if isInferred(t) {
+			res[i], err = inferData(reg, values)
+		} else {
+			res[i], err = scanData(t, values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ep: can't scan NDJSON field %s: %s", GetAlias(t), err)
+		}
+	}
+	return NewDataset(res...), nil
+}
+
+// inferData returns the data of the values, typed by the registered type of
+// their Go type or as a Record of their fields. Values of only nulls are typed
+// as strings
+func inferData(reg typesReg, values []interface{}) (Data, error) {
+	var sample interface{}
+	for _, v := range values {
+		if v != nil {
+			sample = v
+			break
+		}
+	}
+
+	obj, ok := sample.(map[string]interface{})
+	if !ok {
+		key := "string"
+		if sample != nil {
+			key = goTypeKey(reflect.TypeOf(sample))
+		}
+
+		types := reg.Get(key)
+		if len(types) == 0 {
+			return nil, fmt.Errorf("no type is registered for %s", key)
+		}
+		return scanData(types[0], values)
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]Data, len(names))
+	for i, name := range names {
+		fields := make([]interface{}, len(values))
+		for row, v := range values {
+			if v != nil {
+				fields[row] = v.(map[string]interface{})[name]
+			}
+		}
+
+		var err error
+		res[i], err = inferData(reg, fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return NewDataset(res...), nil
+}
+
+// scanData returns the data of the values, set by the ScannerData of the type
+func scanData(t Type, values []interface{}) (Data, error) {
+	data, ok := t.Data(len(values)).(ScannerData)
+	if !ok {
+		return nil, fmt.Errorf("%s data doesn't implement ScannerData", t.Name())
+	}
+
+	for row, v := range values {
+		if v == nil {
+			data.MarkNull(row)
+			continue
+		}
+
+		err := data.Scan(row, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// WriteNDJSON returns a new terminal Runner that writes its input to the
+// writer as newline-delimited JSON objects, whose fields are named by the
+// aliases of the schema, or UnnamedColumn for unnamed columns. The values are
+// converted like they're converted by Rows, except for Record datasets that
+// are written as arrays of their values, as their columns aren't named. It
+// doesn't produce any output. The objects are buffered until its input is
+// closed, so a run that fails midway may leave a truncated part of its rows in
+// the writer
+func WriteNDJSON(w io.Writer, schema []Type) Runner {
+	return &ndjsonWriter{w, schema}
+}
+
+type ndjsonWriter struct {
+	Writer io.Writer
+	Schema []Type
+}
+
+func (r *ndjsonWriter) Equals(other interface{}) bool { return r == other }
+func (*ndjsonWriter) Returns() []Type                 { return []Type{} }
+func (r *ndjsonWriter) Run(ctx context.Context, inp, out chan Dataset) error {
+	names := make([]string, len(r.Schema))
+	for i, t := range r.Schema {
+		names[i] = GetAlias(t)
+		if names[i] == "" {
+			names[i] = UnnamedColumn
+		}
+	}
+
+	w := bufio.NewWriter(r.Writer)
+	enc := json.NewEncoder(w)
+	for data := range inp {
+		if data.Width() != len(names) {
+			return fmt.Errorf("ep: can't write %d columns as NDJSON of %d fields", data.Width(), len(names))
+		}
+
+		cols := jsonValues(data)
+		for row := 0; row < data.Len(); row++ {
+			obj := make(map[string]interface{}, len(names))
+			for i, name := range names {
+				obj[name] = cols[i][row]
+			}
+
+			err := enc.Encode(obj)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// jsonValues returns the values of each of the columns of the dataset, where
+// Records are arrays of their values
+func jsonValues(data Dataset) [][]interface{} {
+	res := make([][]interface{}, data.Width())
+	for i := range res {
+		col := data.At(i)
+		res[i] = make([]interface{}, col.Len())
+		if nested, ok := col.(Dataset); ok {
+			fields := jsonValues(nested)
+			for row := range res[i] {
+				values := make([]interface{}, len(fields))
+				for j := range fields {
+					values[j] = fields[j][row]
+				}
+				res[i][row] = values
+			}
+			continue
+		}
+
+		for row, v := range driverValues(col) {
+			res[i][row] = v
+		}
+	}
+	return res
+}
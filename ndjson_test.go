@@ -0,0 +1,122 @@
+package ep_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestReadNDJSON(t *testing.T) {
+	input := `{"name": "alice", "age": 30, "extra": true}
+{"name": "bob"}
+
+{"age": 50, "name": "carol"}
+`
+	schema := []ep.Type{ep.SetAlias(str, "name"), ep.SetAlias(integer, "age")}
+	runner := ep.ReadNDJSON(strings.NewReader(input), schema, ep.NDJSONOptions{})
+	require.Equal(t, schema, runner.Returns())
+
+	data, err := eptest.Run(runner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"(alice,30)", "(bob,0)", "(carol,50)"}, data.Strings())
+}
+
+func TestReadNDJSON_any(t *testing.T) {
+	input := `{"id": 1, "v": "a"}
+{"id": 2, "v": null}
+{"id": 3, "v": {"x": "b", "y": "c"}}
+{"id": 4, "v": {"x": "d", "y": null}}
+{"id": 5, "v": {"x": "e", "y": "f"}}
+{"id": 6, "v": "g"}
+`
+	schema := []ep.Type{ep.SetAlias(integer, "id"), ep.SetAlias(ep.Any, "v")}
+	runner := ep.ReadNDJSON(strings.NewReader(input), schema, ep.NDJSONOptions{})
+
+	var batches []ep.Dataset
+	inp, out := make(chan ep.Dataset), make(chan ep.Dataset)
+	close(inp)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- runner.Run(context.Background(), inp, out)
+		close(out)
+	}()
+	for data := range out {
+		batches = append(batches, data)
+	}
+	require.NoError(t, <-errs)
+
+	// a batch ends whenever the types change, including the kinds of the
+	// fields of nested objects
+	require.Equal(t, 5, len(batches))
+	require.Equal(t, []string{"(1,a)", "(2,)"}, batches[0].Strings())
+	require.Equal(t, "string", batches[0].At(1).Type().Name())
+	require.Equal(t, []string{"(3,(b,c))"}, batches[1].Strings())
+	require.Equal(t, ep.Record, batches[1].At(1).Type())
+	require.Equal(t, []string{"(4,(d,))"}, batches[2].Strings())
+	require.Equal(t, []string{"(5,(e,f))"}, batches[3].Strings())
+	require.Equal(t, []string{"(6,g)"}, batches[4].Strings())
+}
+
+func TestReadNDJSON_registry(t *testing.T) {
+	// the types of Any columns are picked from the registry of the context
+	reg := ep.NewRegistry()
+	reg.Types.Register("float64", integer)
+	ctx := ep.WithRegistry(context.Background(), reg)
+
+	schema := []ep.Type{ep.SetAlias(ep.Any, "v")}
+	runner := ep.ReadNDJSON(strings.NewReader(`{"v": 1.5}`), schema, ep.NDJSONOptions{})
+	data, err := eptest.RunWithContext(ctx, runner)
+	require.NoError(t, err)
+	require.Equal(t, "integer", data.At(0).Type().Name())
+}
+
+func TestReadNDJSON_batches(t *testing.T) {
+	input := strings.Repeat(`{"s": "a"}`+"\n", 5)
+	schema := []ep.Type{ep.SetAlias(str, "s")}
+	runner := ep.ReadNDJSON(strings.NewReader(input), schema, ep.NDJSONOptions{BatchSize: 2})
+
+	data, err := eptest.Run(ep.Pipeline(runner, &count{}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"(2)", "(2)", "(1)"}, data.Strings())
+}
+
+func TestReadNDJSON_errors(t *testing.T) {
+	schema := []ep.Type{ep.SetAlias(ep.Any, "v")}
+
+	r := strings.NewReader(`{"v": "a"}` + "\n" + `{"v": `)
+	_, err := eptest.Run(ep.ReadNDJSON(r, schema, ep.NDJSONOptions{}))
+	require.EqualError(t, err, "ep: can't decode NDJSON line 2: unexpected end of JSON input")
+
+	r = strings.NewReader(`{"v": 1.5}`)
+	_, err = eptest.Run(ep.ReadNDJSON(r, schema, ep.NDJSONOptions{}))
+	require.EqualError(t, err, "ep: can't scan NDJSON field v: no type is registered for float64")
+
+	r = strings.NewReader(`{"v": "a"}`)
+	schema = []ep.Type{ep.SetAlias(integer, "v")}
+	_, err = eptest.Run(ep.ReadNDJSON(r, schema, ep.NDJSONOptions{}))
+	require.EqualError(t, err, "ep: can't scan NDJSON field v: expected int but got string")
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	schema := []ep.Type{ep.SetAlias(str, "name"), integer, ep.SetAlias(ep.Record, "r")}
+	data := ep.NewDataset(
+		strs{"alice", ""},
+		nullableInts{integers{30, -1}},
+		ep.NewDataset(strs{"a", "b"}, strs{"c", "d"}),
+	)
+
+	res, err := eptest.Run(ep.WriteNDJSON(&buf, schema), data)
+	require.NoError(t, err)
+	require.Nil(t, res)
+	require.Equal(t, `{"?column?":30,"name":"alice","r":["a","c"]}
+{"?column?":null,"name":null,"r":["b","d"]}
+`, buf.String())
+
+	_, err = eptest.Run(ep.WriteNDJSON(&buf, schema[:1]), data)
+	require.EqualError(t, err, "ep: can't write 3 columns as NDJSON of 1 fields")
+}
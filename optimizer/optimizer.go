@@ -0,0 +1,78 @@
+// Package optimizer rewrites composed runners into equivalent runners that
+// are cheaper to execute, by repeatedly applying rewrite rules until none of
+// them changes the runner. The built-in rules drive the optional interfaces
+// that runners already expose for such rewrites: ep.FilterRunner,
+// ep.PushRunner, ep.ScopesRunner and ep.ColumnsRunner.
+//
+//      runner, err := optimizer.Optimize(runner, optimizer.DefaultRules...)
+package optimizer
+
+import (
+	"errors"
+	"github.com/panoplyio/ep"
+)
+
+// MaxPasses is the maximum number of passes over the runner before giving up
+// on reaching a fixpoint, see Optimize
+const MaxPasses = 100
+
+// ErrNoFixpoint is returned by Optimize when the rules keep rewriting the
+// runner after MaxPasses passes
+var ErrNoFixpoint = errors.New("optimizer: rules didn't reach a fixpoint")
+
+// Rule is a single rewrite of runners, applied by Optimize to every runner in
+// the composed runner tree
+type Rule interface {
+	// Match returns true if the rule applies to the runner
+	Match(r ep.Runner) bool
+
+	// Rewrite returns the rewritten runner for runners matched by Match, and
+	// true if it changed the runner, or the runner as-is and false if it
+	// can't be rewritten after all. It must not modify the runner in place
+	Rewrite(r ep.Runner) (ep.Runner, bool)
+}
+
+// Optimize applies the rules to the runner and all of its inner runners,
+// bottom-up, and repeats until the rules no longer change the runner, as
+// reported by their Rewrite. The provided runner isn't modified
+func Optimize(r ep.Runner, rules ...Rule) (ep.Runner, error) {
+	for i := 0; i < MaxPasses; i++ {
+		var changed bool
+		r, changed = pass(r, rules)
+		if !changed {
+			return r, nil
+		}
+	}
+	return nil, ErrNoFixpoint
+}
+
+// pass applies the rules once to every runner in the tree, and returns true
+// if any of them changed it
+func pass(r ep.Runner, rules []Rule) (ep.Runner, bool) {
+	changed := false
+
+	kind, children := ep.Decompose(r)
+	if kind != ep.NotComposite {
+		for i, child := range children {
+			var childChanged bool
+			children[i], childChanged = pass(child, rules)
+			changed = changed || childChanged
+		}
+		if changed {
+			r = ep.Recompose(r, children)
+		}
+	}
+
+	for _, rule := range rules {
+		if !rule.Match(r) {
+			continue
+		}
+
+		rewritten, ruleChanged := rule.Rewrite(r)
+		if ruleChanged {
+			r = rewritten
+			changed = true
+		}
+	}
+	return r, changed
+}
@@ -0,0 +1,160 @@
+package optimizer_test
+
+import (
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/optimizer"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// source is a runner that accepts predicates pushed into it
+type source struct{ Predicates []ep.Runner }
+
+func (s *source) Equals(other interface{}) bool {
+	o, ok := other.(*source)
+	return ok && len(s.Predicates) == len(o.Predicates)
+}
+
+func (*source) Returns() []ep.Type    { return []ep.Type{ep.Wildcard} }
+func (*source) Scopes() ep.StringsSet { return ep.StringsSet{} }
+func (*source) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		out <- data
+	}
+	return nil
+}
+
+func (s *source) Push(toPush ep.ScopesRunner) bool {
+	if _, ok := toPush.(*predicate); !ok {
+		return false
+	}
+	s.Predicates = append(s.Predicates, toPush)
+	return true
+}
+
+func (s *source) Clone() ep.Runner {
+	return &source{append([]ep.Runner(nil), s.Predicates...)}
+}
+
+// predicate is a runner that can be pushed into a source
+type predicate struct{ source }
+
+func (p *predicate) Equals(other interface{}) bool {
+	_, ok := other.(*predicate)
+	return ok
+}
+
+func (p *predicate) Clone() ep.Runner { return &predicate{} }
+
+// neverEq is a runner that conservatively never equals any runner
+type neverEq struct{ ep.Runner }
+
+func (neverEq) Equals(interface{}) bool { return false }
+
+func children(t *testing.T, r ep.Runner) []ep.Runner {
+	kind, res := ep.Decompose(r)
+	require.Equal(t, ep.PipelineComposite, kind)
+	return res
+}
+
+func TestOptimize_flattenPipelines(t *testing.T) {
+	a, b, c := ep.Pick(0), ep.Pick(1), ep.Pick(2)
+	nested := ep.Recompose(ep.Pipeline(a, b), []ep.Runner{a, ep.Recompose(ep.Pipeline(a, b), []ep.Runner{b, c})})
+
+	res, err := optimizer.Optimize(nested, optimizer.FlattenPipelines)
+	require.NoError(t, err)
+	require.Equal(t, []ep.Runner{a, b, c}, children(t, res))
+
+	single := ep.Recompose(ep.Pipeline(a, b), []ep.Runner{a})
+	res, err = optimizer.Optimize(single, optimizer.FlattenPipelines)
+	require.NoError(t, err)
+	require.Equal(t, a, res)
+}
+
+func TestOptimize_pushDown(t *testing.T) {
+	src := &source{}
+	pred1, pred2 := &predicate{}, &predicate{}
+	runner := ep.Pipeline(src, pred1, ep.Pick(0), pred2)
+
+	res, err := optimizer.Optimize(runner, optimizer.PushDown)
+	require.NoError(t, err)
+	optimized := children(t, res)
+	require.Equal(t, []ep.Runner{ep.Pick(0), pred2}, optimized[1:])
+	require.Equal(t, []ep.Runner{pred1}, optimized[0].(*source).Predicates)
+
+	// the original runner isn't modified
+	require.Empty(t, src.Predicates)
+	require.Equal(t, 4, len(children(t, runner)))
+}
+
+func TestOptimize_pruneColumns(t *testing.T) {
+	runner := ep.Pipeline(ep.Project(ep.Pick(0), ep.Pick(1), ep.Pick(2)), ep.Pick(1))
+
+	res, err := optimizer.Optimize(runner, optimizer.PruneColumns)
+	require.NoError(t, err)
+
+	_, projected := ep.Decompose(children(t, res)[0])
+	require.Equal(t, "dummy", projected[0].Returns()[0].Name())
+	require.Equal(t, ep.Pick(1), projected[1])
+	require.Equal(t, "dummy", projected[2].Returns()[0].Name())
+}
+
+func TestOptimize_mergeCompose(t *testing.T) {
+	cmp1 := ep.Compose(nil, ep.PassThrough().(ep.Composable))
+	cmp2 := ep.Compose(nil, ep.PassThrough().(ep.Composable))
+	runner := ep.Pipeline(&source{}, cmp1, cmp2)
+	require.Equal(t, 3, len(children(t, runner)))
+
+	res, err := optimizer.Optimize(runner, optimizer.MergeCompose)
+	require.NoError(t, err)
+
+	merged := children(t, res)
+	require.Equal(t, 2, len(merged))
+	require.Equal(t, ep.Compose(ep.StringsSet{}, cmp1.(ep.Composable), cmp2.(ep.Composable)), merged[1])
+}
+
+func TestOptimize_defaultRules(t *testing.T) {
+	src := &source{}
+	runner := ep.Pipeline(src, &predicate{}, ep.Project(ep.Pick(0), ep.Pick(1)), ep.Pick(0))
+
+	res, err := optimizer.Optimize(runner, optimizer.DefaultRules...)
+	require.NoError(t, err)
+
+	optimized := children(t, res)
+	require.Equal(t, 3, len(optimized))
+	require.Equal(t, 1, len(optimized[0].(*source).Predicates))
+	_, projected := ep.Decompose(optimized[1])
+	require.Equal(t, "dummy", projected[1].Returns()[0].Name())
+	require.Empty(t, src.Predicates)
+}
+
+func TestOptimize_conservativeEquals(t *testing.T) {
+	// runners that are never Equal don't prevent reaching a fixpoint
+	project := ep.Project(ep.PassThrough(), ep.PassThrough())
+	runner := ep.Pipeline(neverEq{ep.PassThrough()}, project, ep.Pick(0))
+
+	res, err := optimizer.Optimize(runner, optimizer.DefaultRules...)
+	require.NoError(t, err)
+
+	require.Equal(t, "dummy", children(t, res)[1].Returns()[1].Name())
+
+	// the original runner isn't modified
+	require.Equal(t, ep.Wildcard, project.Returns()[1])
+}
+
+// toggle is a rule that never reaches a fixpoint
+type toggle struct{}
+
+func (toggle) Match(r ep.Runner) bool { return r.Equals(ep.Pick(0)) || r.Equals(ep.Pick(1)) }
+func (toggle) Rewrite(r ep.Runner) (ep.Runner, bool) {
+	if r.Equals(ep.Pick(0)) {
+		return ep.Pick(1), true
+	}
+	return ep.Pick(0), true
+}
+
+func TestOptimize_noFixpoint(t *testing.T) {
+	_, err := optimizer.Optimize(ep.Pick(0), toggle{})
+	require.Equal(t, optimizer.ErrNoFixpoint, err)
+}
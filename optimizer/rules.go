@@ -0,0 +1,240 @@
+package optimizer
+
+import (
+	"github.com/panoplyio/ep"
+)
+
+// DefaultRules are the built-in rules, in the order they should be applied
+var DefaultRules = []Rule{FlattenPipelines, PushDown, PruneColumns, MergeCompose}
+
+// FlattenPipelines is a Rule that inlines pipelines nested within other
+// pipelines, and unwraps pipelines of a single runner. Such pipelines might be
+// produced by other rules that remove or replace runners
+var FlattenPipelines Rule = flattenPipelines{}
+
+type flattenPipelines struct{}
+
+func (flattenPipelines) Match(r ep.Runner) bool {
+	kind, children := ep.Decompose(r)
+	if kind != ep.PipelineComposite {
+		return false
+	}
+	if len(children) == 1 {
+		return true
+	}
+	for _, child := range children {
+		if kind, _ := ep.Decompose(child); kind == ep.PipelineComposite {
+			return true
+		}
+	}
+	return false
+}
+
+func (flattenPipelines) Rewrite(r ep.Runner) (ep.Runner, bool) {
+	_, children := ep.Decompose(r)
+	var flat []ep.Runner
+	for _, child := range children {
+		kind, nested := ep.Decompose(child)
+		if kind == ep.PipelineComposite {
+			flat = append(flat, nested...)
+		} else {
+			flat = append(flat, child)
+		}
+	}
+	return pipeline(r, flat), true
+}
+
+// PushDown is a Rule that pushes runners into the preceding runners of a
+// pipeline, when they accept them via ep.PushRunner. It's typically used for
+// pushing predicates closer to the source of the data, or into the source
+// itself. The runners are pushed into clones of the preceding runners (see
+// ep.Clone), which are left as-is when they can't be cloned
+var PushDown Rule = pushDown{}
+
+type pushDown struct{}
+
+func (pushDown) Match(r ep.Runner) bool {
+	kind, children := ep.Decompose(r)
+	if kind != ep.PipelineComposite {
+		return false
+	}
+	for i := 1; i < len(children); i++ {
+		_, isPushRunner := children[i-1].(ep.PushRunner)
+		_, isScopesRunner := children[i].(ep.ScopesRunner)
+		if isPushRunner && isScopesRunner {
+			return true
+		}
+	}
+	return false
+}
+
+func (pushDown) Rewrite(r ep.Runner) (ep.Runner, bool) {
+	_, children := ep.Decompose(r)
+	res := []ep.Runner{children[0]}
+	changed := false
+	for _, child := range children[1:] {
+		last := len(res) - 1
+		_, isPushRunner := res[last].(ep.PushRunner)
+		toPush, isScopesRunner := child.(ep.ScopesRunner)
+		if isPushRunner && isScopesRunner {
+			prev, ok := clone(res[last])
+			if ok && prev.(ep.PushRunner).Push(toPush) {
+				res[last] = prev
+				changed = true
+				continue // pushed, no longer needed in the pipeline
+			}
+		}
+		res = append(res, child)
+	}
+
+	if !changed {
+		return r, false
+	}
+	return pipeline(r, res), true
+}
+
+// PruneColumns is a Rule that filters out the columns produced by runners of
+// a pipeline via ep.FilterRunner, when the following runner doesn't use them
+// according to ep.ColumnsRunner (like ep.Pick and ep.Tail). Like in PushDown,
+// clones of the runners are filtered
+var PruneColumns Rule = pruneColumns{}
+
+type pruneColumns struct{}
+
+func (pruneColumns) Match(r ep.Runner) bool {
+	kind, children := ep.Decompose(r)
+	if kind != ep.PipelineComposite {
+		return false
+	}
+	for i := 1; i < len(children); i++ {
+		if _, used := prunable(children[i-1], children[i]); used != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (pruneColumns) Rewrite(r ep.Runner) (ep.Runner, bool) {
+	_, children := ep.Decompose(r)
+	changed := false
+	for i := 1; i < len(children); i++ {
+		filter, used := prunable(children[i-1], children[i])
+		if used == nil {
+			continue
+		}
+
+		// the filtered runner is kept only if it replaced any of its columns
+		// with dummies, as columns might've been pruned already, or might not
+		// be prunable at all
+		filtered, ok := clone(filter)
+		if !ok {
+			continue
+		}
+		filtered.(ep.FilterRunner).Filter(used)
+		if !sameTypeNames(filter.Returns(), filtered.Returns()) {
+			children[i-1] = filtered
+			changed = true
+		}
+	}
+
+	if !changed {
+		return r, false
+	}
+	return ep.Recompose(r, children), true
+}
+
+// prunable returns the columns of the producer that are used by the consumer,
+// or nil if the producer can't be pruned
+func prunable(producer, consumer ep.Runner) (ep.FilterRunner, []bool) {
+	filter, isFilter := producer.(ep.FilterRunner)
+	columns, isColumns := consumer.(ep.ColumnsRunner)
+	if !isFilter || !isColumns {
+		return nil, nil
+	}
+
+	used := columns.UsedColumns(len(filter.Returns()))
+	for _, isUsed := range used {
+		if !isUsed {
+			return filter, used
+		}
+	}
+	return nil, nil // all columns are used
+}
+
+// MergeCompose is a Rule that merges adjacent ep.Composable runners of a
+// pipeline into a single Compose runner, which runs their BatchFunctions one
+// after the other without passing the data between goroutines
+var MergeCompose Rule = mergeCompose{}
+
+type mergeCompose struct{}
+
+func (mergeCompose) Match(r ep.Runner) bool {
+	kind, children := ep.Decompose(r)
+	if kind != ep.PipelineComposite {
+		return false
+	}
+	for i := 1; i < len(children); i++ {
+		_, isCmp1 := children[i-1].(ep.Composable)
+		_, isCmp2 := children[i].(ep.Composable)
+		if isCmp1 && isCmp2 {
+			return true
+		}
+	}
+	return false
+}
+
+func (mergeCompose) Rewrite(r ep.Runner) (ep.Runner, bool) {
+	_, children := ep.Decompose(r)
+	var res, composables []ep.Runner
+	flush := func() {
+		if len(composables) > 0 {
+			// Pipeline merges runners into Compose if all of them are Composables
+			res = append(res, ep.Pipeline(composables...))
+			composables = nil
+		}
+	}
+
+	for _, child := range children {
+		if _, isCmp := child.(ep.Composable); isCmp {
+			composables = append(composables, child)
+		} else {
+			flush()
+			res = append(res, child)
+		}
+	}
+	flush()
+	return pipeline(r, res), true // see Match
+}
+
+// pipeline returns a pipeline of the provided runners in place of r, or the
+// runner itself when there's only one
+func pipeline(r ep.Runner, runners []ep.Runner) ep.Runner {
+	if len(runners) == 1 {
+		return runners[0]
+	}
+	return ep.Recompose(r, runners)
+}
+
+// clone returns a clone of the runner that can be modified in place, see
+// ep.Clone, or false if it can't be cloned
+func clone(r ep.Runner) (res ep.Runner, ok bool) {
+	defer func() {
+		if recover() != nil {
+			res, ok = nil, false
+		}
+	}()
+	return ep.Clone(r), true
+}
+
+// sameTypeNames returns true if the types have the same names
+func sameTypeNames(ts1, ts2 []ep.Type) bool {
+	if len(ts1) != len(ts2) {
+		return false
+	}
+	for i, t := range ts1 {
+		if t.Name() != ts2[i].Name() {
+			return false
+		}
+	}
+	return true
+}
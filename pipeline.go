@@ -2,6 +2,7 @@ package ep
 
 import (
 	"context"
+	"errors"
 	"sync"
 )
 
@@ -72,9 +73,9 @@ func (rs pipeline) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 
 	defer func() {
 		wg.Wait()
-		for _, e := range errs {
-			if e != nil && e != ErrIgnorable && e != errOnPeer {
-				err = e
+		for i, e := range errs {
+			if e != nil && !errors.Is(e, ErrIgnorable) && !errors.Is(e, errOnPeer) {
+				err = atRunner(e, "pipeline", i)
 				break
 			}
 		}
@@ -93,7 +94,7 @@ func (rs pipeline) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 		wg.Add(1)
 		go func(i int, inp, middle chan Dataset) {
 			defer wg.Done()
-			Run(ctx, rs[i], inp, middle, cancel, &errs[i])
+			Run(withPath(ctx, "pipeline", i), rs[i], inp, middle, cancel, &errs[i])
 		}(i, inp, middle)
 
 		// input to the next channel is the output from the current one
@@ -108,7 +109,7 @@ func (rs pipeline) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 	defer cancel()
 
 	// block until last runner completion
-	errs[lastIndex] = rs[lastIndex].Run(ctx, inp, out)
+	errs[lastIndex] = runInstrumented(withPath(ctx, "pipeline", lastIndex), rs[lastIndex], inp, out)
 	return
 }
 
@@ -210,6 +211,8 @@ func (rs pipeline) Push(toPush ScopesRunner) bool {
 	return false
 }
 
+func (rs pipeline) Idempotent() bool { return areIdempotent(rs) }
+
 func (rs pipeline) ApproxSize() int {
 	for i := len(rs) - 1; i >= 0; i-- {
 		r := rs[i]
@@ -4,18 +4,22 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-// Runners registry. See Registries in the main doc.
-var Runners = make(runnersReg)
+// Runners registry of the default Registry. See Registries in the main doc.
+var Runners = defaultRegistry.Runners
 
-// Types registry. See Registries in the main doc.
-var Types = make(typesReg)
+// Types registry of the default Registry. See Registries in the main doc.
+var Types = defaultRegistry.Types
 
 // Plan a new Runner marked by an arbitrary argument that must've been
 // preregistered using the `Runners.Register()` function. if the arg is a
 // struct, it's first converted into a string by reflecting its full type name
-// and path. See Planning & Registries in the main doc.
+// and path. See Planning & Registries in the main doc. The planned runners are
+// cached when the context is created by WithPlanCache.
 func Plan(ctx context.Context, k interface{}) (Runner, error) {
 	return PlanWithArgs(ctx, k, nil)
 }
@@ -56,55 +60,164 @@ func PlanList(ctx context.Context, items []interface{}) ([]Runner, error) {
 }
 
 // PlanWithArgs is similar to Plan, except that it first filters the runners to
-// only keep RunnerArgs instances that have the args provided.
+// only keep RunnerArgs instances that have the args provided. Runners whose
+// Args() can be reached from the args through implicit casts (see
+// Types.RegisterCast) are also planned, in order of their total cast cost
+// after the runners that match the args exactly, and then by priority. Their
+// input is converted by the casts before reaching the planned runner.
 func PlanWithArgs(ctx context.Context, k interface{}, args []Type) (Runner, error) {
-	var err error
-	var rs []Runner
-	if args != nil {
-		rs = Runners.GetWithArgs(k, args)
+	cache := getPlanCache(ctx)
+	if cache == nil {
+		return planWithArgs(ctx, k, args)
+	}
+
+	reg := GetRegistry(ctx)
+	version := atomic.LoadUint64(&runnersVersion)
+	if r, ok := cache.get(reg, k, args, version); ok {
+		return r, nil
+	}
+
+	r, err := planWithArgs(ctx, k, args)
+	if err == nil {
+		cache.put(reg, k, args, r, version)
+	}
+	return r, err
+}
+
+func planWithArgs(ctx context.Context, k interface{}, args []Type) (Runner, error) {
+	var overloads []overload
+	var rejected []RejectedRunner
+	if reg := GetRegistry(ctx); args != nil {
+		overloads, rejected = reg.overloads(k, args)
 	} else {
-		rs = Runners.Get(k)
+		for _, r := range reg.Runners.Get(k) {
+			overloads = append(overloads, overload{Runner: r})
+		}
 	}
 
-	for _, r := range rs {
+	if len(overloads) == 0 {
+		// none of the runners matches the args, not even through casts
+		return nil, &errUnregistered{k}
+	}
+
+	for _, o := range overloads {
 		// check if the runner is plannable
-		p, ok := r.(RunnerPlan)
+		p, ok := o.Runner.(RunnerPlan)
 		if !ok {
 			// not a plannable Runner, return it as-is.
-			return r, nil
+			return o.wrap(o.Runner), nil
 		}
 
 		// otherwise - let it plan itself
-		r, err = p.Plan(ctx, k)
+		r, err := p.Plan(ctx, k)
 		if err == nil {
-			return r, nil
+			return o.wrap(r), nil
 		}
+		rejected = append(rejected, RejectedRunner{o.Runner, err})
 	}
 
-	if err == nil {
-		err = &errUnregistered{k}
+	return nil, &PlanError{k, rejected}
+}
+
+// Registry holds runners and types that are accessible via their keys, see
+// Registries in the main doc. The global Runners and Types registries are
+// backed by a default Registry, while separate registries allow multiple
+// engines within the same process to register different runners for the same
+// keys. Plan resolves runners from the registry attached to the context by
+// WithRegistry. It's safe for concurrent use:
+//
+//      reg := ep.NewRegistry()
+//      reg.Runners.Register("SUM", &SumRunner{})
+//      ctx = ep.WithRegistry(ctx, reg)
+//      ep.Plan(ctx, "SUM") // returns &SumRunner{}
+type Registry struct {
+	Runners runnersReg
+	Types   typesReg
+
+	l          sync.RWMutex
+	runners    map[interface{}][]Runner
+	priorities map[interface{}][]int // of the runners, see RegisterWithPriority
+	types      map[interface{}][]Type
+	casts      map[string][]cast // by the name of the source type
+}
+
+// NewRegistry returns a new empty Registry
+func NewRegistry() *Registry {
+	reg := &Registry{
+		runners:    make(map[interface{}][]Runner),
+		priorities: make(map[interface{}][]int),
+		types:      make(map[interface{}][]Type),
+		casts:      make(map[string][]cast),
 	}
+	reg.Runners = runnersReg{reg}
+	reg.Types = typesReg{reg}
+	return reg
+}
 
-	return nil, err
+// defaultRegistry backs the global Runners and Types registries
+var defaultRegistry = NewRegistry()
+
+// WithRegistry returns a new context that plans runners from the provided
+// Registry, instead of the global Runners registry
+func WithRegistry(ctx context.Context, reg *Registry) context.Context {
+	return context.WithValue(ctx, scopedRegistryKey, reg)
+}
+
+// GetRegistry returns the Registry attached to the context by WithRegistry, or
+// the default Registry of the global Runners and Types registries
+func GetRegistry(ctx context.Context) *Registry {
+	reg, _ := ctx.Value(scopedRegistryKey).(*Registry)
+	if reg == nil {
+		return defaultRegistry
+	}
+	return reg
 }
 
 // registry of runners
 // by convention - lowercase is function names, uppercase is SQL constructs
-type runnersReg map[interface{}][]Runner
+type runnersReg struct{ reg *Registry }
+
+// runnersVersion is incremented whenever runners are registered to any
+// registry, in order to invalidate planned runners, see PlanCache
+var runnersVersion uint64
 
 // Register a key-runner pair to be globally accessible via the Get() function
 // using the same key.
 func (reg runnersReg) Register(k interface{}, r Runner) runnersReg {
+	return reg.RegisterWithPriority(k, r, 0)
+}
+
+// RegisterWithPriority is similar to Register, except that runners of higher
+// priority are returned by Get(), and thus planned, before runners of lower
+// priority. Runners of the same priority are returned in the order of their
+// registration. Register uses a priority of 0.
+func (reg runnersReg) RegisterWithPriority(k interface{}, r Runner, priority int) runnersReg {
 	registerGob(k, r)
 	k = registryKey(k)
-	reg[k] = append(reg[k], r)
+
+	reg.reg.l.Lock()
+	defer reg.reg.l.Unlock()
+	rs, priorities := reg.reg.runners[k], reg.reg.priorities[k]
+	i := len(priorities)
+	for i > 0 && priorities[i-1] < priority {
+		i--
+	}
+
+	// new slices are allocated, as the previous ones may be used by readers
+	reg.reg.runners[k] = append(append(append([]Runner{}, rs[:i]...), r), rs[i:]...)
+	reg.reg.priorities[k] = append(append(append([]int{}, priorities[:i]...), priority), priorities[i:]...)
+	atomic.AddUint64(&runnersVersion, 1)
 	return reg
 }
 
 // Get a list of Runners that were previously registered to the provided key
 // via the Register() function.
 func (reg runnersReg) Get(k interface{}) []Runner {
-	return reg[registryKey(k)]
+	k = registryKey(k)
+
+	reg.reg.l.RLock()
+	defer reg.reg.l.RUnlock()
+	return reg.reg.runners[k]
 }
 
 // GetWithArgs is similar to Get() except that it first filters the runners to
@@ -122,7 +235,7 @@ func (reg runnersReg) GetWithArgs(k interface{}, args []Type) []Runner {
 }
 
 // registry of types
-type typesReg map[interface{}][]Type
+type typesReg struct{ reg *Registry }
 
 // Register a key-type pair to be globally accessible via the Get() function
 // using the same key.
@@ -133,21 +246,31 @@ func (reg typesReg) Register(k interface{}, t Type) typesReg {
 
 func (reg typesReg) register(k interface{}, t Type) typesReg {
 	k = registryKey(k)
-	reg[k] = append(reg[k], t)
+
+	reg.reg.l.Lock()
+	defer reg.reg.l.Unlock()
+	reg.reg.types[k] = append(reg.reg.types[k], t)
 	return reg
 }
 
 // Get a list of Types that were previously registered to the provided key
 // via the Register() function.
 func (reg typesReg) Get(k interface{}) []Type {
-	return reg[registryKey(k)]
+	k = registryKey(k)
+
+	reg.reg.l.RLock()
+	defer reg.reg.l.RUnlock()
+	return reg.reg.types[k]
 }
 
 // All returns all registered types without duplications. useful for
 // tests and code generation
 func (reg typesReg) All() []Type {
+	reg.reg.l.RLock()
+	defer reg.reg.l.RUnlock()
+
 	typesSet := make(map[Type]bool)
-	for _, list := range reg {
+	for _, list := range reg.reg.types {
 		for _, t := range list {
 			typesSet[t] = true
 		}
@@ -178,6 +301,36 @@ func registryKey(k interface{}) interface{} {
 	return k
 }
 
+// PlanError is returned by Plan when all of the runners registered to the
+// planned argument that match its args failed to plan it. When none of them
+// matches its args, the argument is considered unregistered instead. It lists
+// every runner that was considered, along with the reason it was rejected, in
+// order to allow debugging which runner refused the argument. It unwraps to
+// the error of the last runner that failed to plan the argument
+type PlanError struct {
+	Arg        interface{}
+	Candidates []RejectedRunner
+}
+
+// RejectedRunner is a registered runner that was rejected by Plan, see
+// PlanError
+type RejectedRunner struct {
+	Runner Runner
+	Err    error
+}
+
+func (err *PlanError) Error() string {
+	reasons := make([]string, len(err.Candidates))
+	for i, c := range err.Candidates {
+		reasons[i] = runnerName(c.Runner) + ": " + c.Err.Error()
+	}
+	return fmt.Sprintf("Failed to plan %s: %s", reflect.TypeOf(err.Arg), strings.Join(reasons, "; "))
+}
+
+func (err *PlanError) Unwrap() error {
+	return err.Candidates[len(err.Candidates)-1].Err
+}
+
 // error indicating that an unregistered argument was sent to Plan
 type errUnregistered struct{ Arg interface{} }
 
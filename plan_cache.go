@@ -0,0 +1,169 @@
+package ep
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+)
+
+// PlanCache is a least-recently-used cache of planned runners, that allows
+// skipping the planning of arguments that were already planned, like the
+// same statement that's executed repeatedly. It's used by Plan, PlanList and
+// PlanWithArgs when the context is created by WithPlanCache:
+//
+//      cache := ep.NewPlanCache(1000)
+//      ctx = ep.WithPlanCache(ctx, cache)
+//      runner, err := ep.Plan(ctx, stmt) // planned once per stmt
+//
+// Arguments are looked up by equality (==), so arguments of struct types are
+// matched by their fields, while pointers are matched by their identity.
+// Arguments that aren't comparable, like slices, are always planned. Planning
+// is assumed to depend only on the argument, and not on the context.
+//
+// Every lookup returns a fresh copy of the planned runner, see Clone, so it
+// can be executed concurrently with other copies. Runners that can't be cloned
+// are not cached. The cache is invalidated whenever the Runners registry
+// changes. It's safe for concurrent use
+type PlanCache struct {
+	l       sync.Mutex
+	size    int
+	version uint64 // of the Runners registry, see runnersVersion
+	entries map[cacheKey]*list.Element
+	lru     *list.List // of *cacheEntry, from the most recently used
+}
+
+type cacheKey struct {
+	Registry *Registry // see WithRegistry
+	Arg      interface{}
+	Args     string // names of the args types, see PlanWithArgs
+	WithArgs bool
+}
+
+type cacheEntry struct {
+	key    cacheKey
+	runner Runner // never executed, only copied
+}
+
+// NewPlanCache returns a new PlanCache that holds up to size planned runners
+func NewPlanCache(size int) *PlanCache {
+	return &PlanCache{
+		size:    size,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// WithPlanCache returns a new context that caches the planned runners in the
+// provided PlanCache
+func WithPlanCache(ctx context.Context, cache *PlanCache) context.Context {
+	return context.WithValue(ctx, planCacheKey, cache)
+}
+
+func getPlanCache(ctx context.Context) *PlanCache {
+	cache, _ := ctx.Value(planCacheKey).(*PlanCache)
+	return cache
+}
+
+// Len returns the number of cached runners
+func (c *PlanCache) Len() int {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.lru.Len()
+}
+
+// Purge removes all of the cached runners
+func (c *PlanCache) Purge() {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.purge()
+}
+
+func (c *PlanCache) purge() {
+	c.entries = make(map[cacheKey]*list.Element)
+	c.lru.Init()
+}
+
+// get returns a copy of the runner planned for the arguments from the
+// registry, as long as the registries haven't changed since it was planned
+func (c *PlanCache) get(reg *Registry, k interface{}, args []Type, version uint64) (Runner, bool) {
+	key, ok := newCacheKey(reg, k, args)
+	if !ok {
+		return nil, false
+	}
+
+	c.l.Lock()
+	if c.version != version {
+		c.purge()
+		c.version = version
+	}
+
+	elm, ok := c.entries[key]
+	if !ok {
+		c.l.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(elm)
+	r := elm.Value.(*cacheEntry).runner
+	c.l.Unlock()
+
+	res, err := cloneRunner(r)
+	return res, err == nil
+}
+
+// put caches a copy of the runner planned for the arguments from the
+// registry, while the registries were at the provided version
+func (c *PlanCache) put(reg *Registry, k interface{}, args []Type, r Runner, version uint64) {
+	key, ok := newCacheKey(reg, k, args)
+	if !ok || c.size <= 0 {
+		return
+	}
+
+	// the planned runner is returned to the caller, that might run it, so
+	// the cache keeps its own copy
+	r, err := cloneRunner(r)
+	if err != nil {
+		return
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.version != version {
+		return // planned with a stale registry
+	}
+
+	if elm, ok := c.entries[key]; ok {
+		elm.Value.(*cacheEntry).runner = r
+		c.lru.MoveToFront(elm)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, r})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// newCacheKey returns the key of the arguments, or false if they can't be
+// used as a key
+func newCacheKey(reg *Registry, k interface{}, args []Type) (key cacheKey, ok bool) {
+	key = cacheKey{Registry: reg, Arg: k, WithArgs: args != nil}
+	if args != nil {
+		names := make([]string, len(args))
+		for i, t := range args {
+			names[i] = t.Name()
+		}
+		key.Args = strings.Join(names, ",")
+	}
+
+	// hashing a key panics if it isn't comparable
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = map[cacheKey]bool{key: true}
+	return key, true
+}
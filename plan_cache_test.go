@@ -0,0 +1,97 @@
+package ep_test
+
+import (
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+)
+
+// selectStmt is a planned argument, planned by selectPlanner that counts the
+// number of times it's called
+type selectStmt struct{ Table string }
+
+type selectPlanner struct{ upper }
+
+var selectPlans int64
+
+func (*selectPlanner) Plan(ctx context.Context, arg interface{}) (ep.Runner, error) {
+	atomic.AddInt64(&selectPlans, 1)
+	return ep.Pipeline(ep.Scatter(), &upper{}, ep.Gather()), nil
+}
+
+var _ = ep.Runners.Register(selectStmt{}, &selectPlanner{})
+
+// listStmt isn't comparable, so it's never cached
+type listStmt struct{ Tables []string }
+
+var _ = ep.Runners.Register(listStmt{}, &selectPlanner{})
+
+func TestPlanCache(t *testing.T) {
+	cache := ep.NewPlanCache(10)
+	ctx := ep.WithPlanCache(context.Background(), cache)
+	before := atomic.LoadInt64(&selectPlans)
+
+	r1, err := ep.Plan(ctx, selectStmt{"a"})
+	require.NoError(t, err)
+	r2, err := ep.Plan(ctx, selectStmt{"a"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), atomic.LoadInt64(&selectPlans)-before)
+	require.Equal(t, 1, cache.Len())
+
+	// copies are equal, but don't share exchanges
+	require.True(t, r1.Equals(r2))
+	require.NotEqual(t, ep.Spew(r1), ep.Spew(r2))
+
+	_, err = ep.Plan(ctx, selectStmt{"b"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), atomic.LoadInt64(&selectPlans)-before)
+	require.Equal(t, 2, cache.Len())
+
+	cache.Purge()
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestPlanCache_lru(t *testing.T) {
+	cache := ep.NewPlanCache(2)
+	ctx := ep.WithPlanCache(context.Background(), cache)
+	before := atomic.LoadInt64(&selectPlans)
+
+	for _, table := range []string{"a", "b", "a", "c", "a", "b"} {
+		_, err := ep.Plan(ctx, selectStmt{table})
+		require.NoError(t, err)
+	}
+
+	// b was evicted by c, as a was used more recently
+	require.Equal(t, int64(4), atomic.LoadInt64(&selectPlans)-before)
+	require.Equal(t, 2, cache.Len())
+}
+
+func TestPlanCache_invalidation(t *testing.T) {
+	cache := ep.NewPlanCache(10)
+	ctx := ep.WithPlanCache(context.Background(), cache)
+	before := atomic.LoadInt64(&selectPlans)
+
+	_, err := ep.Plan(ctx, selectStmt{"a"})
+	require.NoError(t, err)
+
+	ep.Runners.Register("planCacheInvalidation", &upper{})
+	_, err = ep.Plan(ctx, selectStmt{"a"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), atomic.LoadInt64(&selectPlans)-before)
+	require.Equal(t, 1, cache.Len())
+}
+
+func TestPlanCache_notComparable(t *testing.T) {
+	cache := ep.NewPlanCache(10)
+	ctx := ep.WithPlanCache(context.Background(), cache)
+	before := atomic.LoadInt64(&selectPlans)
+
+	for i := 0; i < 2; i++ {
+		_, err := ep.Plan(ctx, listStmt{[]string{"a"}})
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(2), atomic.LoadInt64(&selectPlans)-before)
+	require.Equal(t, 0, cache.Len())
+}
@@ -0,0 +1,93 @@
+package ep_test
+
+import (
+	"context"
+	"errors"
+	"github.com/panoplyio/ep"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// refusingPlanner is a RunnerPlan that always fails with its error
+type refusingPlanner struct {
+	upper
+	Reason string
+}
+
+func (r *refusingPlanner) Equals(other interface{}) bool {
+	o, ok := other.(*refusingPlanner)
+	return ok && r.Reason == o.Reason
+}
+
+func (r *refusingPlanner) Plan(context.Context, interface{}) (ep.Runner, error) {
+	return nil, errors.New(r.Reason)
+}
+
+// refusingSum is a sum that always fails to plan with its error
+type refusingSum struct {
+	sum
+	Reason string
+}
+
+func (r *refusingSum) Plan(context.Context, interface{}) (ep.Runner, error) {
+	return nil, errors.New(r.Reason)
+}
+
+func TestRunners_RegisterWithPriority(t *testing.T) {
+	reg := ep.NewRegistry()
+	reg.Runners.
+		Register("priority", &refusingPlanner{Reason: "a"}).
+		RegisterWithPriority("priority", &refusingPlanner{Reason: "b"}, 10).
+		RegisterWithPriority("priority", &refusingPlanner{Reason: "c"}, -1).
+		RegisterWithPriority("priority", &refusingPlanner{Reason: "d"}, 10).
+		Register("priority", &refusingPlanner{Reason: "e"})
+
+	var reasons []string
+	for _, r := range reg.Runners.Get("priority") {
+		reasons = append(reasons, r.(*refusingPlanner).Reason)
+	}
+	require.Equal(t, []string{"b", "d", "a", "e", "c"}, reasons)
+
+	reg.Runners.RegisterWithPriority("priority", &upper{}, 1)
+	r, err := ep.Plan(ep.WithRegistry(context.Background(), reg), "priority")
+	require.NoError(t, err)
+	require.IsType(t, &upper{}, r)
+}
+
+func TestPlan_error(t *testing.T) {
+	reg := ep.NewRegistry()
+	reg.Runners.
+		Register("refused", &refusingPlanner{Reason: "unsupported"}).
+		Register("refused", &sum{int8}).
+		RegisterWithPriority("refused", &refusingPlanner{Reason: "too complex"}, 1)
+	ctx := ep.WithRegistry(context.Background(), reg)
+
+	// none of the runners matches the args
+	_, err := ep.PlanWithArgs(ctx, "refused", []ep.Type{int4})
+	var unregistered interface{ UnregisteredArg() interface{} }
+	require.True(t, errors.As(err, &unregistered))
+	require.Equal(t, "refused", unregistered.UnregisteredArg())
+
+	// runners that match the args and then fail are listed along with the
+	// mismatching ones
+	reg.Runners.Register("refused", &refusingSum{sum{int4}, "overflow"})
+	_, err = ep.PlanWithArgs(ctx, "refused", []ep.Type{int4})
+	require.Equal(t, "Failed to plan string: "+
+		"ep_test.refusingPlanner: doesn't declare its args; "+
+		"ep_test.refusingPlanner: doesn't declare its args; "+
+		"ep_test.sum: expects args [int8] but receives [int4]; "+
+		"ep_test.refusingSum: overflow", err.Error())
+
+	reg.Runners.
+		Register("refusedPlan", &refusingPlanner{Reason: "unsupported"}).
+		RegisterWithPriority("refusedPlan", &refusingPlanner{Reason: "too complex"}, 1)
+	_, err = ep.Plan(ctx, "refusedPlan")
+	require.Error(t, err)
+
+	var planErr *ep.PlanError
+	require.True(t, errors.As(err, &planErr))
+	require.Equal(t, "refusedPlan", planErr.Arg)
+	require.Len(t, planErr.Candidates, 2)
+	require.Equal(t, "too complex", planErr.Candidates[0].Err.Error())
+	require.Equal(t, "unsupported", errors.Unwrap(err).Error())
+}
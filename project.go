@@ -103,9 +103,9 @@ func (rs project) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 	defer func() {
 		wg.Wait()
 		// choose first error out from all errors, that isn't project internal error
-		for _, errI := range errs {
+		for i, errI := range errs {
 			if err == nil && errI != nil {
-				err = errI
+				err = atRunner(errI, "project", i)
 				break
 			}
 		}
@@ -120,7 +120,7 @@ func (rs project) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 		wg.Add(1)
 		go func(idx int) {
 			defer wg.Done()
-			Run(ctx, rs[idx], inps[idx], outs[idx], cancel, &errs[idx])
+			Run(withPath(ctx, "project", idx), rs[idx], inps[idx], outs[idx], cancel, &errs[idx])
 		}(i)
 	}
 
@@ -215,6 +215,8 @@ func (rs project) Scopes() StringsSet {
 	return scopes
 }
 
+func (rs project) Idempotent() bool { return areIdempotent(rs) }
+
 func (rs project) ApproxSize() int {
 	totalSize := 0
 	for _, r := range rs {
@@ -254,8 +256,9 @@ func (*dummyRunner) Equals(other interface{}) bool {
 	_, ok := other.(*dummyRunner)
 	return ok
 }
-func (*dummyRunner) Args() []Type    { return []Type{Wildcard} }
-func (*dummyRunner) Returns() []Type { return []Type{dummy} }
+func (*dummyRunner) Args() []Type     { return []Type{Wildcard} }
+func (*dummyRunner) Returns() []Type  { return []Type{dummy} }
+func (*dummyRunner) Idempotent() bool { return true }
 func (*dummyRunner) Run(_ context.Context, inp, out chan Dataset) error {
 	return nil
 }
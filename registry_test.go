@@ -0,0 +1,68 @@
+package ep_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/panoplyio/ep"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := ep.NewRegistry()
+	reg.Runners.Register("registryUpper", &upper{})
+	reg.Types.Register("registryStr", str)
+	ctx := ep.WithRegistry(context.Background(), reg)
+
+	r, err := ep.Plan(ctx, "registryUpper")
+	require.NoError(t, err)
+	require.IsType(t, &upper{}, r)
+	require.Equal(t, []ep.Type{str}, reg.Types.Get("registryStr"))
+
+	// the scoped registry isn't shared with the global registries
+	_, err = ep.Plan(context.Background(), "registryUpper")
+	require.Error(t, err)
+	require.Empty(t, ep.Types.Get("registryStr"))
+
+	// and vice versa
+	_, err = ep.Plan(ctx, "upper")
+	require.Error(t, err)
+	require.Equal(t, ep.Runners, ep.GetRegistry(context.Background()).Runners)
+}
+
+func TestRegistry_separateEngines(t *testing.T) {
+	reg1, reg2 := ep.NewRegistry(), ep.NewRegistry()
+	reg1.Runners.Register("registryEngine", &upper{})
+	reg2.Runners.Register("registryEngine", &question{})
+
+	cache := ep.NewPlanCache(10)
+	for _, tc := range []struct {
+		reg      *ep.Registry
+		expected ep.Runner
+	}{{reg1, &upper{}}, {reg2, &question{}}, {reg1, &upper{}}} {
+		ctx := ep.WithPlanCache(ep.WithRegistry(context.Background(), tc.reg), cache)
+		r, err := ep.Plan(ctx, "registryEngine")
+		require.NoError(t, err)
+		require.IsType(t, tc.expected, r)
+	}
+}
+
+func TestRegistry_concurrentRegister(t *testing.T) {
+	reg := ep.NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reg.Runners.Register("registryConcurrent", &upper{})
+			reg.Types.Register(fmt.Sprintf("registryConcurrent%d", i), str)
+			reg.Runners.Get("registryConcurrent")
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, reg.Runners.Get("registryConcurrent"), 10)
+	require.Len(t, reg.Types.All(), 1)
+}
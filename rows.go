@@ -3,8 +3,11 @@ package ep
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
 	"io"
+	"reflect"
 	"strings"
+	"sync"
 )
 
 // Rows creates a new Runner that also implements driver.Rows, useful for cases
@@ -13,7 +16,61 @@ import (
 // runner cannot be distributed and thus should only be used at the top-level
 // client-facing code. It's safe to cast the returned Runner to driver.Rows.
 func Rows(ctx context.Context, r Runner) Runner {
-	return &rows{Runner: r, Ctx: ctx}
+	return &rows{Runner: r, Ctx: ctx, BaseCtx: ctx}
+}
+
+// MultiRows is similar to Rows, except that each of the runners produces a
+// separate result set, like the statements of a multi-statement script. The
+// runners are run one after the other, where the next runner is only run
+// after the result set of the previous one is consumed, see
+// driver.RowsNextResultSet. It's safe to cast the returned Runner to
+// driver.RowsNextResultSet.
+func MultiRows(ctx context.Context, runners ...Runner) Runner {
+	if len(runners) == 0 {
+		panic("at least 1 runner is required for rows")
+	}
+	return &rows{Runner: runners[0], Pending: runners[1:], Ctx: ctx, BaseCtx: ctx}
+}
+
+// ValuerData is a Data that also exposes its values as native driver.Values,
+// which are returned by Rows instead of the Strings() of the Data. Other Data
+// is returned as strings, except for nulls which are always returned as nil
+type ValuerData interface {
+	Data // it's a Data
+
+	// Value returns the value of the row as one of the driver.Value types, or
+	// nil if it's null
+	Value(row int) driver.Value
+}
+
+// ScanTyper is a Type that also declares the Go type of the values of its
+// ValuerData, as reported by the ColumnTypeScanType of Rows
+type ScanTyper interface {
+	Type // it's a Type
+
+	// ScanType returns the Go type of the values, see
+	// driver.RowsColumnTypeScanType
+	ScanType() reflect.Type
+}
+
+// LengthTyper is a Type of variable length values, see
+// driver.RowsColumnTypeLength
+type LengthTyper interface {
+	Type // it's a Type
+
+	// Length returns the maximum length of the values, or false for ok if the
+	// type isn't of variable length
+	Length() (length int64, ok bool)
+}
+
+// DecimalTyper is a Type of decimal values, see
+// driver.RowsColumnTypePrecisionScale
+type DecimalTyper interface {
+	Type // it's a Type
+
+	// PrecisionScale returns the precision and scale of the values, or false
+	// for ok if it's not applicable
+	PrecisionScale() (precision, scale int64, ok bool)
 }
 
 // rows is a runner that also implements driver.Rows
@@ -22,8 +79,14 @@ type rows struct {
 	Ctx        context.Context
 	CancelFunc context.CancelFunc
 	Out        chan Dataset
-	Buff       [][]string // buffer of received rows waiting for Next()
+	Buff       [][]driver.Value // buffer of received rows waiting for Next()
 	Err        error
+	Pending    []Runner        // runners of the next result sets, see MultiRows
+	BaseCtx    context.Context // of all result sets, while Ctx is of the current one
+
+	l      sync.Mutex    // guards the following fields, shared by Next and Close
+	done   chan struct{} // closed when the runner started by Next returns
+	closed bool
 }
 
 func (r *rows) Equals(other interface{}) bool {
@@ -55,31 +118,103 @@ func (r *rows) Columns() []string {
 	return cols
 }
 
-// see driver.Rows. Blocks until the runner has indeed finished
+// see driver.Rows. Cancels the runner, and blocks until it has indeed
+// finished, while discarding its remaining output. Returns the error of the
+// runner, unless it's caused by the cancellation. It's safe to call Close
+// concurrently with Next, which returns the error of the runner or io.EOF
+// once the runner is closed
 func (r *rows) Close() error {
-	if r.CancelFunc == nil {
-		return nil // it never started.
-	}
+	r.l.Lock()
+	r.closed = true
+	r.l.Unlock()
+	return r.finish()
+}
 
-	r.CancelFunc()
+// finish cancels the runner of the current result set, and waits for it to
+// end, see Close
+func (r *rows) finish() error {
+	r.l.Lock()
+	done, cancel := r.done, r.CancelFunc
+	r.l.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	if done == nil {
+		return nil // it never started, or it's run externally via Run
+	}
 
 	// wait for it to end.
-	return nil // return the error.
+	for range r.Out {
+	}
+	<-done
+
+	if errors.Is(r.Err, context.Canceled) {
+		return nil
+	}
+	return r.Err
+}
+
+// see driver.RowsNextResultSet
+func (r *rows) HasNextResultSet() bool {
+	return len(r.Pending) > 0
+}
+
+// see driver.RowsNextResultSet. The remaining rows of the current result set
+// are discarded, and its runner is canceled
+func (r *rows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+
+	if err := r.finish(); err != nil {
+		return err
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	if r.closed {
+		return io.EOF
+	}
+
+	r.Runner, r.Pending = r.Pending[0], r.Pending[1:]
+	r.Ctx, r.CancelFunc = r.BaseCtx, nil
+	r.Out, r.Buff, r.Err, r.done = nil, nil, nil, nil
+	return nil
+}
+
+// start runs the runner in the background, unless it's already started, or
+// closed. Returns false if it's closed before it was started
+func (r *rows) start() bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if r.Out != nil {
+		return true
+	} else if r.closed {
+		return false
+	}
+
+	r.Out = make(chan Dataset)
+	r.done = make(chan struct{})
+	ctx, cancel := context.WithCancel(r.Ctx)
+	r.Ctx, r.CancelFunc = ctx, cancel // for Close()
+
+	// kick it off with one empty input
+	inp := make(chan Dataset)
+	close(inp)
+	go func(out chan Dataset) {
+		r.Err = r.Runner.Run(ctx, inp, out)
+		close(out)
+		close(r.done)
+	}(r.Out)
+	return true
 }
 
 // see driver.Rows
 func (r *rows) Next(dest []driver.Value) error {
 	// Not running? start it.
-	if r.Out == nil {
-		r.Out = make(chan Dataset)
-
-		// kick it off with one empty input
-		inp := make(chan Dataset)
-		close(inp)
-		go func() {
-			defer close(r.Out)
-			r.Err = r.Run(r.Ctx, inp, r.Out)
-		}()
+	if !r.start() {
+		return io.EOF
 	}
 
 	// if we still have buffered rows, emit the first one and remove it
@@ -103,19 +238,16 @@ func (r *rows) Next(dest []driver.Value) error {
 		return io.EOF
 	}
 
-	// build the batch of data
-	columnar := ColumnStrings(data)
-
-	// transpose the columnar strings to rows of strings for the buffer.
-	rows := make([][]string, data.Len())
+	// transpose the columnar values to rows of values for the buffer.
+	rows := make([][]driver.Value, data.Len())
 	dataWidth := data.Width()
 	for i := range rows {
-		row := make([]string, dataWidth)
-		for j := range row {
-			row[j] = columnar[j][i]
+		rows[i] = make([]driver.Value, dataWidth)
+	}
+	for j := 0; j < dataWidth; j++ {
+		for i, v := range driverValues(data.At(j)) {
+			rows[i][j] = v
 		}
-
-		rows[i] = row
 	}
 
 	r.Buff = rows
@@ -124,13 +256,57 @@ func (r *rows) Next(dest []driver.Value) error {
 	return r.Next(dest)
 }
 
+// driverValues returns the values of the data as driver.Values, see ValuerData
+func driverValues(data Data) []driver.Value {
+	res := make([]driver.Value, data.Len())
+	if valuer, ok := data.(ValuerData); ok {
+		for i := range res {
+			res[i] = valuer.Value(i)
+		}
+		return res
+	}
+
+	strs := data.Strings()
+	for i := range res {
+		if !data.IsNull(i) {
+			res[i] = strs[i]
+		}
+	}
+	return res
+}
+
 // see driver.ColumnTypeDatabaseTypeName
 func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
 	types := r.Returns()
 	return strings.ToUpper(types[index].Name())
 }
 
-// see driver.RowsColumnTypeNullable
+// see driver.RowsColumnTypeNullable, and SetNullable
 func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
-	return true, true
+	return IsNullable(r.Returns()[index])
+}
+
+// see driver.RowsColumnTypeScanType, and ScanTyper. Values of other types are
+// either strings or nils, so they're scanned into an interface{}
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	if t, ok := UnderlyingType(r.Returns()[index]).(ScanTyper); ok {
+		return t.ScanType()
+	}
+	return reflect.TypeOf((*interface{})(nil)).Elem()
+}
+
+// see driver.RowsColumnTypeLength, and LengthTyper
+func (r *rows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if t, ok := UnderlyingType(r.Returns()[index]).(LengthTyper); ok {
+		return t.Length()
+	}
+	return 0, false
+}
+
+// see driver.RowsColumnTypePrecisionScale, and DecimalTyper
+func (r *rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if t, ok := UnderlyingType(r.Returns()[index]).(DecimalTyper); ok {
+		return t.PrecisionScale()
+	}
+	return 0, 0, false
 }
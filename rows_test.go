@@ -3,10 +3,13 @@ package ep_test
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
 	"github.com/panoplyio/ep"
 	"github.com/stretchr/testify/require"
 	"io"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestRows(t *testing.T) {
@@ -33,3 +36,180 @@ func TestRows(t *testing.T) {
 	require.Equal(t, "HELLO", res[0])
 	require.Equal(t, "WORLD", res[1])
 }
+
+// nullableInts are integers exposed as driver.Values, where negative values
+// are nulls
+type nullableInts struct{ integers }
+
+func (vs nullableInts) Value(row int) driver.Value {
+	if vs.integers[row] < 0 {
+		return nil
+	}
+	return int64(vs.integers[row])
+}
+
+// typedData produces its data, with its declared types
+type typedData struct {
+	ep.Dataset
+	Types []ep.Type
+}
+
+func (*typedData) Equals(other interface{}) bool { return false }
+func (r *typedData) Returns() []ep.Type          { return r.Types }
+func (r *typedData) Run(_ context.Context, _, out chan ep.Dataset) error {
+	out <- r.Dataset
+	return nil
+}
+
+// int64Type is an integer type that declares its column metadata
+type int64Type struct{ *integerType }
+
+func (int64Type) ScanType() reflect.Type                            { return reflect.TypeOf(int64(0)) }
+func (int64Type) Length() (int64, bool)                             { return 0, false }
+func (int64Type) PrecisionScale() (precision, scale int64, ok bool) { return 19, 0, true }
+
+func TestRows_values(t *testing.T) {
+	data := ep.NewDataset(nullableInts{integers{1, -1, 3}}, strs{"hello", "", "world"})
+	types := []ep.Type{ep.SetNullable(ep.SetAlias(int64Type{integer}, "id"), true), ep.SetNullable(str, false)}
+	runner := &typedData{data, types}
+	rows := ep.Rows(context.Background(), runner).(driver.Rows)
+	defer rows.Close()
+
+	var res [][]driver.Value
+	for {
+		dest := make([]driver.Value, 2)
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+		res = append(res, dest)
+	}
+
+	require.Equal(t, [][]driver.Value{{int64(1), "hello"}, {nil, nil}, {int64(3), "world"}}, res)
+
+	typed := rows.(interface {
+		driver.RowsColumnTypeNullable
+		driver.RowsColumnTypeScanType
+		driver.RowsColumnTypeLength
+		driver.RowsColumnTypePrecisionScale
+	})
+
+	nullable, ok := typed.ColumnTypeNullable(0)
+	require.True(t, ok)
+	require.True(t, nullable)
+	nullable, ok = typed.ColumnTypeNullable(1)
+	require.True(t, ok)
+	require.False(t, nullable)
+
+	require.Equal(t, reflect.TypeOf(int64(0)), typed.ColumnTypeScanType(0))
+	require.Equal(t, reflect.TypeOf((*interface{})(nil)).Elem(), typed.ColumnTypeScanType(1))
+
+	_, ok = typed.ColumnTypeLength(0)
+	require.False(t, ok)
+
+	precision, scale, ok := typed.ColumnTypePrecisionScale(0)
+	require.True(t, ok)
+	require.Equal(t, []int64{19, 0}, []int64{precision, scale})
+	_, _, ok = typed.ColumnTypePrecisionScale(1)
+	require.False(t, ok)
+}
+
+func TestRows_Close(t *testing.T) {
+	runner := &waitForCancel{}
+	rows := ep.Rows(context.Background(), runner).(driver.Rows)
+
+	dest := make([]driver.Value, 1)
+	require.NoError(t, rows.Next(dest))
+	require.True(t, runner.IsRunning())
+
+	require.NoError(t, rows.Close())
+	require.False(t, runner.IsRunning())
+	require.NoError(t, rows.Close())
+	require.Equal(t, io.EOF, rows.Next(dest))
+}
+
+func TestRows_Close_error(t *testing.T) {
+	err := errors.New("failure")
+	runner := ep.Pipeline(&fixedData{Dataset: ep.NewDataset(strs{"hello"})}, &failAfterData{err})
+	rows := ep.Rows(context.Background(), runner).(driver.Rows)
+
+	dest := make([]driver.Value, 1)
+	require.NoError(t, rows.Next(dest))
+	require.True(t, errors.Is(rows.Close(), err))
+}
+
+func TestRows_Close_notStarted(t *testing.T) {
+	runner := &waitForCancel{}
+	rows := ep.Rows(context.Background(), runner).(driver.Rows)
+	require.NoError(t, rows.Close())
+	require.Equal(t, io.EOF, rows.Next(make([]driver.Value, 1)))
+	require.False(t, runner.IsRunning())
+}
+
+func TestRows_Close_concurrentNext(t *testing.T) {
+	rows := ep.Rows(context.Background(), &untilCanceled{}).(driver.Rows)
+
+	errs := make(chan error)
+	go func() { errs <- rows.Next(make([]driver.Value, 1)) }()
+
+	time.Sleep(10 * time.Millisecond) // let Next block
+	require.NoError(t, rows.Close())
+	require.True(t, errors.Is(<-errs, context.Canceled))
+}
+
+// failAfterData passes its input through, and then fails
+type failAfterData struct{ Err error }
+
+func (*failAfterData) Equals(other interface{}) bool { return false }
+func (*failAfterData) Returns() []ep.Type            { return []ep.Type{ep.Wildcard} }
+func (r *failAfterData) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		out <- data
+	}
+	return r.Err
+}
+
+// untilCanceled produces no output until it's canceled
+type untilCanceled struct{}
+
+func (*untilCanceled) Equals(other interface{}) bool { return false }
+func (*untilCanceled) Returns() []ep.Type            { return []ep.Type{str} }
+func (*untilCanceled) Run(ctx context.Context, inp, out chan ep.Dataset) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestMultiRows(t *testing.T) {
+	rows := ep.MultiRows(context.Background(),
+		ep.Pipeline(&fixedData{Dataset: ep.NewDataset(strs{"hello", "world"})}, &upper{}),
+		&waitForCancel{},
+		&fixedData{Dataset: ep.NewDataset(strs{"foo"})},
+	).(driver.RowsNextResultSet)
+	defer rows.Close()
+
+	readAll := func() (res []driver.Value) {
+		dest := make([]driver.Value, 1)
+		for rows.Next(dest) == nil {
+			res = append(res, dest[0])
+		}
+		return res
+	}
+
+	require.Equal(t, []string{"upper"}, rows.Columns())
+	require.Equal(t, []driver.Value{"HELLO", "WORLD"}, readAll())
+
+	// result sets that aren't fully consumed are canceled
+	require.True(t, rows.HasNextResultSet())
+	require.NoError(t, rows.NextResultSet())
+	require.NoError(t, rows.Next(make([]driver.Value, 1)))
+
+	require.True(t, rows.HasNextResultSet())
+	require.NoError(t, rows.NextResultSet())
+	require.Equal(t, []string{ep.UnnamedColumn}, rows.Columns())
+	require.Equal(t, []driver.Value{"foo"}, readAll())
+
+	require.False(t, rows.HasNextResultSet())
+	require.Equal(t, io.EOF, rows.NextResultSet())
+}
@@ -116,6 +116,17 @@ type FilterRunner interface {
 	Filter(keep []bool)
 }
 
+// ColumnsRunner is a Runner that also declares which of its input columns it
+// actually uses. Columns that aren't used may be replaced with dummies by the
+// preceding runner, see FilterRunner
+type ColumnsRunner interface {
+	Runner // it's a Runner
+
+	// UsedColumns returns, for an input of n columns, whether each column is
+	// used by the Runner
+	UsedColumns(n int) []bool
+}
+
 // ScopesRunner is a Runner that also exposes the ability
 // to get all scopes involved
 type ScopesRunner interface {
@@ -142,6 +153,32 @@ type ApproxSizer interface {
 	ApproxSize() int
 }
 
+// IdempotentRunner is a Runner that also declares whether it's safe to re-run
+// it after a failure. For example, read-only Runners without side effects
+type IdempotentRunner interface {
+	Runner // it's a Runner
+
+	// Idempotent returns true if the Runner can be safely re-run
+	Idempotent() bool
+}
+
+// IsIdempotent returns true if the given runner declares itself as idempotent.
+// Runners that don't implement IdempotentRunner are assumed to be unsafe
+func IsIdempotent(r Runner) bool {
+	ir, ok := r.(IdempotentRunner)
+	return ok && ir.Idempotent()
+}
+
+// areIdempotent returns true if all of the given runners are idempotent
+func areIdempotent(rs []Runner) bool {
+	for _, r := range rs {
+		if !IsIdempotent(r) {
+			return false
+		}
+	}
+	return true
+}
+
 // Run runs given runner and takes care of channels management involved in runner execution
 // safe to use only if caller created the out channel
 func Run(ctx context.Context, r Runner, inp, out chan Dataset, cancel context.CancelFunc, err *error) {
@@ -151,7 +188,7 @@ func Run(ctx context.Context, r Runner, inp, out chan Dataset, cancel context.Ca
 	// allows preceding runner to be canceled
 	defer drain(inp)
 	defer close(out)
-	*err = r.Run(ctx, inp, out)
+	*err = runInstrumented(ctx, r, inp, out)
 	if *err != nil && cancel != nil {
 		setError(ctx, *err)
 		cancel()
@@ -190,7 +227,8 @@ func (r *passThrough) Scopes() StringsSet {
 	return r.scopes
 }
 
-func (*passThrough) Args() []Type { return []Type{Wildcard} }
+func (*passThrough) Args() []Type     { return []Type{Wildcard} }
+func (*passThrough) Idempotent() bool { return true }
 func (r *passThrough) Returns() []Type {
 	if len(r.ReturnTypes) == 0 {
 		return []Type{Wildcard}
@@ -229,6 +267,18 @@ func (r *pick) Equals(other interface{}) bool {
 	return true
 }
 
+func (*pick) Idempotent() bool { return true }
+
+func (r *pick) UsedColumns(n int) []bool {
+	used := make([]bool, n)
+	for _, idx := range r.Indices {
+		if idx < n {
+			used[idx] = true
+		}
+	}
+	return used
+}
+
 func (r *pick) Returns() []Type {
 	types := make([]Type, len(r.Indices))
 	for i, idx := range r.Indices {
@@ -276,6 +326,17 @@ func (r *tail) Equals(other interface{}) bool {
 }
 
 func (r *tail) Returns() []Type { return r.Types }
+func (*tail) Idempotent() bool  { return true }
+
+func (r *tail) UsedColumns(n int) []bool {
+	used := make([]bool, n)
+	for i := n - len(r.Types); i < n; i++ {
+		if i >= 0 {
+			used[i] = true
+		}
+	}
+	return used
+}
 
 func (r *tail) Run(_ context.Context, inp, out chan Dataset) error {
 	tailWidth := len(r.Types)
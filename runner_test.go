@@ -80,3 +80,29 @@ func TestRun_callCancel(t *testing.T) {
 	require.Equal(t, "err", err.Error())
 	require.True(t, canceledCalled)
 }
+
+func TestIsIdempotent(t *testing.T) {
+	u, err := ep.Union(ep.PassThrough(), ep.Pick(0))
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name     string
+		r        ep.Runner
+		expected bool
+	}{
+		{name: "built-in", r: ep.Batch(10), expected: true},
+		{name: "exchange", r: ep.Gather(), expected: true},
+		{name: "unknown", r: &upper{}, expected: false},
+		{name: "pipeline", r: ep.Pipeline(ep.Scatter(), ep.Tail(nil), ep.Gather()), expected: true},
+		{name: "pipeline with unknown", r: ep.Pipeline(ep.Scatter(), &upper{}), expected: false},
+		{name: "project with unknown", r: ep.Project(ep.PassThrough(), &upper{}), expected: false},
+		{name: "union", r: u, expected: true},
+		{name: "scope", r: ep.Scope(ep.Pick(0), "s"), expected: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ep.IsIdempotent(tc.r))
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package ep
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ = registerGob(&statsReport{})
+
+// RunnerStats holds the execution metrics of a single Runner. When the Runner
+// was distributed, the metrics are aggregated across all of the nodes.
+type RunnerStats struct {
+	Path  string // path of the runner within the executed runner, see Stats
+	Name  string // name of the runner
+	Nodes int    // number of nodes that ran the runner
+
+	RowsIn     int64 // number of received rows
+	RowsOut    int64 // number of produced rows
+	BatchesIn  int64 // number of received datasets
+	BatchesOut int64 // number of produced datasets
+
+	WallTime         time.Duration // total run time, maximum across nodes
+	BlockedOnSend    time.Duration // time waiting for the next runner to receive
+	BlockedOnReceive time.Duration // time waiting for the previous runner to send
+
+	BytesSent     int64 // bytes sent to other nodes, by exchanges
+	BytesReceived int64 // bytes received from other nodes, by exchanges
+}
+
+func (s *RunnerStats) String() string {
+	str := fmt.Sprintf("rows=%d->%d batches=%d->%d time=%s blocked(send=%s receive=%s)",
+		s.RowsIn, s.RowsOut, s.BatchesIn, s.BatchesOut, s.WallTime,
+		s.BlockedOnSend, s.BlockedOnReceive)
+	if s.BytesSent > 0 || s.BytesReceived > 0 {
+		str += fmt.Sprintf(" bytes(sent=%d received=%d)", s.BytesSent, s.BytesReceived)
+	}
+	if s.Nodes > 1 {
+		str += fmt.Sprintf(" nodes=%d", s.Nodes)
+	}
+	return str
+}
+
+// add aggregates the metrics of the same runner from another node
+func (s *RunnerStats) add(other *RunnerStats) {
+	s.Nodes += other.Nodes
+	s.RowsIn += other.RowsIn
+	s.RowsOut += other.RowsOut
+	s.BatchesIn += other.BatchesIn
+	s.BatchesOut += other.BatchesOut
+	s.BlockedOnSend += other.BlockedOnSend
+	s.BlockedOnReceive += other.BlockedOnReceive
+	s.BytesSent += other.BytesSent
+	s.BytesReceived += other.BytesReceived
+	if other.WallTime > s.WallTime {
+		s.WallTime = other.WallTime
+	}
+}
+
+// Stats collects the execution metrics of all Runners executed with a context
+// returned by WithStats. Each runner is identified by its path within the
+// executed runner, composed of its position within its composite runners, like
+// "pipeline[2]/project[0]". The top-level runner has an empty path.
+type Stats struct {
+	l      sync.Mutex
+	byPath map[string]*RunnerStats
+}
+
+// WithStats returns a new context that enables collection of execution metrics
+// for all Runners executed with it. The metrics are available via GetStats
+// after the execution is done.
+func WithStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, statsKey, &Stats{byPath: make(map[string]*RunnerStats)})
+}
+
+// GetStats returns the execution metrics collected with the given context, or
+// nil if it wasn't created by WithStats
+func GetStats(ctx context.Context) *Stats {
+	stats, _ := ctx.Value(statsKey).(*Stats)
+	return stats
+}
+
+// Get returns the metrics of the runner in the given path, or nil if it
+// wasn't executed
+func (s *Stats) Get(path string) *RunnerStats {
+	if s == nil {
+		return nil
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	rs, ok := s.byPath[path]
+	if !ok {
+		return nil
+	}
+	res := *rs
+	return &res
+}
+
+// All returns the metrics of all executed runners, sorted by their paths
+func (s *Stats) All() []RunnerStats {
+	s.l.Lock()
+	defer s.l.Unlock()
+	res := make([]RunnerStats, 0, len(s.byPath))
+	for _, rs := range s.byPath {
+		res = append(res, *rs)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res
+}
+
+// add aggregates the given metrics into the collected ones
+func (s *Stats) add(all ...RunnerStats) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	for i := range all {
+		rs := &all[i]
+		if existing, ok := s.byPath[rs.Path]; ok {
+			existing.add(rs)
+		} else {
+			res := *rs
+			s.byPath[rs.Path] = &res
+		}
+	}
+}
+
+// statsReport is sent by peers to report back their execution metrics to the
+// master node
+type statsReport struct{ Stats []RunnerStats }
+
+// pathOf returns the path of the runner that is currently executed with
+// the given context
+func pathOf(ctx context.Context) string {
+	path, _ := ctx.Value(pathKey).(string)
+	return path
+}
+
+// withPath returns a context for executing the i-th runner of a composite
+// runner with the given name. It's a no-op when the runners are neither measured
+// nor traced
+func withPath(ctx context.Context, name string, i int) context.Context {
+	if GetStats(ctx) == nil && !isTracing(ctx) {
+		return ctx
+	}
+	step := name
+	if i >= 0 {
+		step = fmt.Sprintf("%s[%d]", name, i)
+	}
+	if path := pathOf(ctx); path != "" {
+		step = path + "/" + step
+	}
+	return context.WithValue(ctx, pathKey, step)
+}
+
+// statsRecorder records the metrics of a single runner execution
+type statsRecorder struct {
+	l sync.Mutex
+	RunnerStats
+}
+
+func (rec *statsRecorder) record(f func(*RunnerStats)) {
+	rec.l.Lock()
+	defer rec.l.Unlock()
+	f(&rec.RunnerStats)
+}
+
+// runInstrumented runs the runner, while recording its execution metrics if
+// the context was created by WithStats, and tracing it if the context has a
+// Tracer, see WithTracer
+func runInstrumented(ctx context.Context, r Runner, inp, out chan Dataset) (err error) {
+	if isTracing(ctx) {
+		var span Span
+		ctx, span = startSpan(ctx, runnerName(r))
+		span.SetAttribute("path", pathOf(ctx))
+		if node := NodeAddress(ctx); node != "" {
+			span.SetAttribute("node", node)
+		}
+		defer func() { span.End(err) }()
+	}
+
+	stats := GetStats(ctx)
+	if stats == nil {
+		return r.Run(ctx, inp, out)
+	}
+
+	rec := &statsRecorder{RunnerStats: RunnerStats{Path: pathOf(ctx), Name: runnerName(r), Nodes: 1}}
+	ctx = context.WithValue(ctx, statsRecorderKey, rec)
+
+	// proxy the input and output of the runner in order to count them, and
+	// measure the time it waits for its input and for its output to be received
+	proxyInp := make(chan Dataset)
+	go func() {
+		defer close(proxyInp)
+		for {
+			start := time.Now()
+			data, ok := <-inp
+			blocked := time.Since(start)
+			rec.record(func(rs *RunnerStats) { rs.BlockedOnReceive += blocked })
+			if !ok {
+				return
+			}
+
+			rec.record(func(rs *RunnerStats) {
+				rs.RowsIn += int64(data.Len())
+				rs.BatchesIn++
+			})
+			proxyInp <- data
+		}
+	}()
+
+	proxyOut := make(chan Dataset)
+	outDone := make(chan struct{})
+	go func() {
+		defer close(outDone)
+		for data := range proxyOut {
+			start := time.Now()
+			out <- data
+			blocked := time.Since(start)
+			rec.record(func(rs *RunnerStats) {
+				rs.RowsOut += int64(data.Len())
+				rs.BatchesOut++
+				rs.BlockedOnSend += blocked
+			})
+		}
+	}()
+
+	start := time.Now()
+	err = r.Run(ctx, proxyInp, proxyOut)
+	close(proxyOut)
+	<-outDone
+
+	// the runner might've exited before its input was exhausted. Keep draining
+	// it to allow the proxy above to complete
+	go drain(proxyInp)
+
+	rec.record(func(rs *RunnerStats) {
+		rs.WallTime = time.Since(start)
+		stats.add(*rs)
+	})
+	return err
+}
+
+// countingConn is a net.Conn that records the bytes sent and received through
+// it, used by exchanges
+type countingConn struct {
+	net.Conn
+	rec *statsRecorder
+}
+
+// countBytes wraps the connection to record its traffic, if the context is
+// currently recording the metrics of a runner
+func countBytes(ctx context.Context, conn net.Conn) net.Conn {
+	rec, ok := ctx.Value(statsRecorderKey).(*statsRecorder)
+	if !ok {
+		return conn
+	}
+	return &countingConn{conn, rec}
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.rec.record(func(rs *RunnerStats) { rs.BytesReceived += int64(n) })
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.rec.record(func(rs *RunnerStats) { rs.BytesSent += int64(n) })
+	return n, err
+}
+
+// runnerName returns a short human-readable name of the runner
+func runnerName(r Runner) string {
+	switch r := r.(type) {
+	case pipeline:
+		return "pipeline"
+	case project:
+		return "project"
+	case union:
+		return "union"
+	case *compose:
+		return "compose"
+	case *distRunner:
+		return "distribute"
+	case *exchange:
+		return r.Type.String()
+	case *alias:
+		return fmt.Sprintf("alias(%s)", r.Label)
+	case *scope:
+		return fmt.Sprintf("scope(%s)", r.Label)
+	}
+	// built-in runners are named without their package
+	name := strings.TrimPrefix(fmt.Sprintf("%T", r), "*")
+	return strings.TrimPrefix(name, "ep.")
+}
+
+func (t exchangeType) String() string {
+	switch t {
+	case gather:
+		return "gather"
+	case sortGather:
+		return "sortGather"
+	case scatter:
+		return "scatter"
+	case broadcast:
+		return "broadcast"
+	case partition:
+		return "partition"
+	}
+	return fmt.Sprintf("exchange(%d)", int(t))
+}
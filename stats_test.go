@@ -0,0 +1,102 @@
+package ep_test
+
+import (
+	"context"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestWithStats(t *testing.T) {
+	runner := ep.Pipeline(&upper{}, ep.Project(ep.PassThrough(), &upper{}))
+
+	ctx := ep.WithStats(context.Background())
+	data1 := ep.NewDataset(strs{"hello", "world"})
+	data2 := ep.NewDataset(strs{"foo"})
+	_, err := eptest.RunWithContext(ctx, runner, data1, data2)
+	require.NoError(t, err)
+
+	stats := ep.GetStats(ctx)
+	require.NotNil(t, stats)
+
+	paths := []string{}
+	for _, rs := range stats.All() {
+		paths = append(paths, rs.Path)
+	}
+	require.Equal(t, []string{
+		"",
+		"pipeline[0]",
+		"pipeline[1]",
+		"pipeline[1]/project[0]",
+		"pipeline[1]/project[1]",
+	}, paths)
+
+	top := stats.Get("")
+	require.Equal(t, "pipeline", top.Name)
+	require.Equal(t, 1, top.Nodes)
+	require.Equal(t, int64(3), top.RowsIn)
+	require.Equal(t, int64(3), top.RowsOut)
+	require.Equal(t, int64(2), top.BatchesIn)
+	require.True(t, top.WallTime > 0)
+
+	rs := stats.Get("pipeline[1]/project[1]")
+	require.Equal(t, "ep_test.upper", rs.Name)
+	require.Equal(t, int64(3), rs.RowsIn)
+	require.Equal(t, int64(3), rs.RowsOut)
+	require.Nil(t, stats.Get("pipeline[2]"))
+
+	explain := ep.ExplainAnalyze(runner, stats)
+	require.True(t, strings.HasPrefix(explain, "pipeline ["), explain)
+	require.Contains(t, strings.Split(explain, "\n")[0], "(rows=3->3 batches=2->2")
+}
+
+func TestWithStats_disabled(t *testing.T) {
+	ctx := context.Background()
+	_, err := eptest.RunWithContext(ctx, &upper{}, ep.NewDataset(strs{"hello"}))
+	require.NoError(t, err)
+	require.Nil(t, ep.GetStats(ctx))
+}
+
+func TestWithStats_distributed(t *testing.T) {
+	dist1 := eptest.NewPeer(t, ":5551")
+	dist2 := eptest.NewPeer(t, ":5552")
+	defer eptest.ClosePeer(t, dist1)
+	defer eptest.ClosePeer(t, dist2)
+
+	runner := ep.Pipeline(ep.Scatter(), &upper{}, ep.Gather())
+	runner = dist1.Distribute(runner, ":5551", ":5552")
+
+	ctx := ep.WithStats(context.Background())
+	data1 := ep.NewDataset(strs{"hello", "world"})
+	data2 := ep.NewDataset(strs{"foo", "bar"})
+	data, err := eptest.RunWithContext(ctx, runner, data1, data2)
+	require.NoError(t, err)
+	require.Equal(t, 4, data.Len())
+
+	stats := ep.GetStats(ctx)
+
+	// upper runs on both nodes, each receiving half of the rows
+	rs := stats.Get("distribute/pipeline[1]")
+	require.Equal(t, 2, rs.Nodes)
+	require.Equal(t, int64(4), rs.RowsIn)
+	require.Equal(t, int64(4), rs.RowsOut)
+
+	scatter := stats.Get("distribute/pipeline[0]")
+	require.Equal(t, "scatter", scatter.Name)
+	require.True(t, scatter.BytesSent > 0)
+	require.True(t, scatter.BytesReceived > 0)
+
+	gather := stats.Get("distribute/pipeline[2]")
+	require.Equal(t, 2, gather.Nodes)
+	require.Equal(t, int64(4), gather.RowsOut, "only master produces the output")
+
+	explain := ep.ExplainAnalyze(runner, stats)
+	lines := strings.Split(explain, "\n")
+	require.True(t, strings.HasPrefix(lines[0], "distribute(:5551, :5552) "), explain)
+	require.Contains(t, lines[0], "(rows=4->4")
+	require.True(t, strings.HasPrefix(lines[3], "    ep_test.upper "), explain)
+	require.Contains(t, lines[3], "(rows=4->4")
+	require.Contains(t, lines[3], "nodes=2")
+}
@@ -0,0 +1,339 @@
+package ep
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var _ = registerGob(&values{})
+
+// ScannerData is a Data that can also be set from native Go values, which is
+// required by FromStructs for the types of all of the struct fields. It's the
+// counterpart of ValuerData, which is used by ScanStructs
+type ScannerData interface {
+	Data // it's a Data
+
+	// Scan sets the value of the row from the provided Go value, or marks it
+	// as null if the value is nil. It returns an error if the value can't be
+	// converted into the Data type
+	Scan(row int, v interface{}) error
+}
+
+// structField is an exported struct field that's mapped to a column
+type structField struct {
+	Index []int
+	Name  string
+	Alias string
+	Key   string // of its type in the Types registry
+}
+
+// structFields returns the columns of the struct type, in the order of its
+// fields. Fields are configured by the "ep" struct tag, which holds the alias
+// of the column followed by the key of its type in the Types registry:
+//
+//      type user struct {
+//          ID    int64  `ep:"id,integer"`
+//          Name  string `ep:"name"`  // type of the "string" key
+//          Notes string `ep:"-"`     // skipped
+//          Email *string             // "Email" alias, may be null
+//      }
+//
+// The alias defaults to the field name, and the key of the type defaults to
+// the name of the Go type of the field, without its pointer. Pointer fields
+// are nulls when they're nil
+func structFields(t reflect.Type) ([]structField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ep: expected a struct but got %s", t)
+	}
+
+	var res []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("ep")
+		if f.PkgPath != "" || tag == "-" {
+			continue // unexported or skipped
+		}
+
+		alias, key := f.Name, goTypeKey(f.Type)
+		parts := strings.SplitN(tag, ",", 2)
+		if parts[0] != "" {
+			alias = parts[0]
+		}
+		if len(parts) > 1 && parts[1] != "" {
+			key = parts[1]
+		}
+
+		res = append(res, structField{f.Index, f.Name, alias, key})
+	}
+	return res, nil
+}
+
+// structTypes returns the aliased types of the struct fields, which are
+// picked from the types registry by their keys
+func structTypes(reg typesReg, fields []structField) ([]Type, error) {
+	res := make([]Type, len(fields))
+	for i, f := range fields {
+		types := reg.Get(f.Key)
+		if len(types) == 0 {
+			return nil, fmt.Errorf("ep: no type is registered for %s field %s", f.Key, f.Name)
+		}
+		res[i] = SetAlias(types[0], f.Alias)
+	}
+	return res, nil
+}
+
+// goTypeKey returns the default Types key of struct fields of the Go type,
+// which is formatted like the keys of struct values, see registryKey
+func goTypeKey(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && t.Name() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
+
+// FromStructs returns a new Dataset with the values of the provided slice of
+// structs, or of pointers to structs, where each exported field is a column.
+// The types of the columns are picked from the Types registry by the "ep"
+// struct tags, and their Data must implement ScannerData, see Values for the
+// aliases of the columns. Nil pointers to structs are rows of nulls
+func FromStructs(slice interface{}) (Dataset, error) {
+	return FromStructsWithContext(context.Background(), slice)
+}
+
+// FromStructsWithContext is similar to FromStructs, except that the types are
+// picked from the Types registry of the context, see GetRegistry
+func FromStructsWithContext(ctx context.Context, slice interface{}) (Dataset, error) {
+	_, data, err := fromStructs(ctx, slice)
+	return data, err
+}
+
+// fromStructs returns the aliased types of the columns of the slice of
+// structs, along with its dataset, see FromStructs
+func fromStructs(ctx context.Context, slice interface{}) ([]Type, Dataset, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		return nil, nil, fmt.Errorf("ep: expected a slice of structs but got %T", slice)
+	}
+
+	fields, err := structFields(indirectType(v.Type().Elem()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	types, err := structTypes(GetRegistry(ctx).Types, fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := make([]Data, len(fields))
+	for i, f := range fields {
+		data, ok := types[i].Data(v.Len()).(ScannerData)
+		if !ok {
+			return nil, nil, fmt.Errorf("ep: %s data doesn't implement ScannerData", types[i].Name())
+		}
+
+		for row := 0; row < v.Len(); row++ {
+			field, ok := fieldByIndex(v.Index(row), f.Index)
+			if !ok || (field.Kind() == reflect.Ptr && field.IsNil()) {
+				data.MarkNull(row)
+				continue
+			}
+
+			err = data.Scan(row, reflect.Indirect(field).Interface())
+			if err != nil {
+				return nil, nil, fmt.Errorf("ep: can't scan %s row %d: %s", f.Alias, row, err)
+			}
+		}
+		res[i] = data
+	}
+	return types, NewDataset(res...), nil
+}
+
+// ScanStructs is the reverse of FromStructs, as it sets the provided pointer
+// to a slice of structs, or of pointers to structs, with the rows of the
+// dataset. The columns are mapped to the exported fields by their order, and
+// their values are converted like they're converted by Rows. Pointer fields
+// are set to nil for nulls, while other fields are set to their zero values
+func ScanStructs(ds Dataset, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ep: expected a pointer to a slice of structs but got %T", dst)
+	}
+
+	elem := v.Elem().Type().Elem()
+	fields, err := structFields(indirectType(elem))
+	if err != nil {
+		return err
+	}
+	if len(fields) != ds.Width() {
+		return fmt.Errorf("ep: can't scan %d columns into %d fields", ds.Width(), len(fields))
+	}
+
+	res := reflect.MakeSlice(v.Elem().Type(), ds.Len(), ds.Len())
+	if elem.Kind() == reflect.Ptr {
+		for row := 0; row < ds.Len(); row++ {
+			res.Index(row).Set(reflect.New(elem.Elem()))
+		}
+	}
+
+	for i, f := range fields {
+		for row, value := range driverValues(ds.At(i)) {
+			field, _ := fieldByIndex(res.Index(row), f.Index)
+			err = assign(field, value)
+			if err != nil {
+				return fmt.Errorf("ep: can't scan %s row %d: %s", f.Alias, row, err)
+			}
+		}
+	}
+
+	v.Elem().Set(res)
+	return nil
+}
+
+// indirectType returns the type pointed to by t, or t if it's not a pointer
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// fieldByIndex returns the nested field of the struct, or of the pointer to
+// struct, or false for ok if the pointer is nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v.FieldByIndex(index), true
+}
+
+// assign sets the field to the value, which is either one of the driver.Value
+// types or a string, see driverValues
+func assign(field reflect.Value, value interface{}) error {
+	if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		err := assign(ptr.Elem(), value)
+		if err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if s, ok := value.(string); ok && field.Kind() != reflect.String {
+		return parse(field, s)
+	}
+
+	// avoid converting between strings and numbers, which reflect considers
+	// to be convertible as runes
+	isString := v.Kind() == reflect.String || v.Kind() == reflect.Slice
+	if isString != (field.Kind() == reflect.String || field.Kind() == reflect.Slice) {
+		return fmt.Errorf("can't convert %T into %s", value, field.Type())
+	} else if !v.Type().ConvertibleTo(field.Type()) {
+		return fmt.Errorf("can't convert %T into %s", value, field.Type())
+	}
+
+	field.Set(v.Convert(field.Type()))
+	return nil
+}
+
+// parse sets the non-string field from its string representation
+func parse(field reflect.Value, s string) error {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("can't convert string into %s", field.Type())
+		}
+		field.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("can't convert string into %s", field.Type())
+	}
+	return nil
+}
+
+// Values returns a new Runner that emits the values of the provided slice of
+// structs as a single dataset, with the aliased columns of the struct fields,
+// see FromStructs. The dataset is converted once, and emitted as-is whenever
+// the Runner runs, without reading its input
+func Values(slice interface{}) (Runner, error) {
+	return ValuesWithContext(context.Background(), slice)
+}
+
+// ValuesWithContext is similar to Values, except that the types are picked
+// from the Types registry of the context, see FromStructsWithContext
+func ValuesWithContext(ctx context.Context, slice interface{}) (Runner, error) {
+	types, data, err := fromStructs(ctx, slice)
+	if err != nil {
+		return nil, err
+	}
+	return &values{types, data}, nil
+}
+
+type values struct {
+	Types []Type
+	Data  Dataset
+}
+
+func (r *values) Equals(other interface{}) bool {
+	o, ok := other.(*values)
+	return ok && AreEqualTypes(r.Types, o.Types) && r.Data.Equal(o.Data)
+}
+
+func (r *values) Returns() []Type { return r.Types }
+func (r *values) ApproxSize() int { return r.Data.Len() }
+func (*values) Idempotent() bool  { return true }
+func (r *values) Run(ctx context.Context, inp, out chan Dataset) error {
+	if r.Data.Len() == 0 {
+		return nil // empty datasets are never sent
+	}
+
+	select {
+	case out <- r.Data:
+	case <-ctx.Done():
+	}
+	return nil
+}
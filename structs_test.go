@@ -0,0 +1,154 @@
+package ep_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var _ = ep.Types.Register("int", integer)
+
+func (vs strs) Scan(row int, v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("expected string but got %T", v)
+	}
+	vs[row] = s
+	return nil
+}
+
+func (vs integers) Scan(row int, v interface{}) error {
+	switch v := v.(type) {
+	case int:
+		vs[row] = v
+	case float64: // decoded from JSON
+		vs[row] = int(v)
+	default:
+		return fmt.Errorf("expected int but got %T", v)
+	}
+	return nil
+}
+
+type user struct {
+	ID      int     `ep:"id,integer"`
+	Name    string  `ep:"name"`
+	Email   *string // nullable
+	Age     int
+	Notes   string `ep:"-"`
+	private string
+}
+
+func TestFromStructs(t *testing.T) {
+	email := "bob@example.com"
+	data, err := ep.FromStructs([]*user{
+		{ID: 1, Name: "alice", Age: 30, Notes: "skipped"},
+		{ID: 2, Name: "bob", Email: &email, Age: 40},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, data.Width())
+	require.Equal(t, []string{"(1,alice,,30)", "(2,bob,bob@example.com,40)"}, data.Strings())
+
+	var res []user
+	err = ep.ScanStructs(data, &res)
+	require.NoError(t, err)
+	require.Equal(t, []user{
+		{ID: 1, Name: "alice", Age: 30},
+		{ID: 2, Name: "bob", Email: &email, Age: 40},
+	}, res)
+}
+
+func TestFromStructs_errors(t *testing.T) {
+	_, err := ep.FromStructs(user{})
+	require.EqualError(t, err, "ep: expected a slice of structs but got ep_test.user")
+
+	_, err = ep.FromStructs([]struct{ F float64 }{{1}})
+	require.EqualError(t, err, "ep: no type is registered for float64 field F")
+
+	_, err = ep.FromStructs([]struct {
+		Q string `ep:",question"`
+	}{{"?"}})
+	require.EqualError(t, err, "ep: no type is registered for question field Q")
+}
+
+func TestFromStructs_registry(t *testing.T) {
+	// the types are picked from the registry of the context
+	type scoped struct {
+		S string `ep:",scopedStr"`
+	}
+	reg := ep.NewRegistry()
+	reg.Types.Register("scopedStr", str)
+	ctx := ep.WithRegistry(context.Background(), reg)
+
+	runner, err := ep.ValuesWithContext(ctx, []scoped{{"a"}})
+	require.NoError(t, err)
+	require.Equal(t, str.Name(), runner.Returns()[0].Name())
+
+	data, err := ep.FromStructsWithContext(ctx, []scoped{{"a"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"(a)"}, data.Strings())
+
+	_, err = ep.FromStructs([]scoped{{"a"}})
+	require.EqualError(t, err, "ep: no type is registered for scopedStr field S")
+}
+
+func TestScanStructs_errors(t *testing.T) {
+	data := ep.NewDataset(strs{"a"}, strs{"b"})
+
+	var users []user
+	err := ep.ScanStructs(data, users)
+	require.EqualError(t, err, "ep: expected a pointer to a slice of structs but got []ep_test.user")
+
+	err = ep.ScanStructs(data, &users)
+	require.EqualError(t, err, "ep: can't scan 2 columns into 4 fields")
+
+	var res []struct{ N int }
+	err = ep.ScanStructs(ep.NewDataset(strs{"a"}), &res)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "ep: can't scan N row 0: ")
+}
+
+func TestValues(t *testing.T) {
+	runner, err := ep.Values([]user{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}})
+	require.NoError(t, err)
+
+	types := runner.Returns()
+	require.Equal(t, 4, len(types))
+	require.Equal(t, "id", ep.GetAlias(types[0]))
+	require.Equal(t, "integer", types[0].Name())
+	require.Equal(t, "name", ep.GetAlias(types[1]))
+	require.Equal(t, "Email", ep.GetAlias(types[2]))
+	require.Equal(t, "Age", ep.GetAlias(types[3]))
+	require.Equal(t, 2, runner.(ep.ApproxSizer).ApproxSize())
+
+	data, err := eptest.Run(ep.Pipeline(runner, ep.Project(ep.Pipeline(ep.Pick(1), &upper{}), ep.Pick(0))))
+	require.NoError(t, err)
+	require.Equal(t, []string{"(ALICE,1)", "(BOB,2)"}, data.Strings())
+
+	clone := ep.Clone(runner)
+	require.True(t, runner.Equals(clone))
+	require.Equal(t, ep.Fingerprint(runner), ep.Fingerprint(clone))
+
+	// empty datasets are never sent
+	runner, err = ep.Values([]user{})
+	require.NoError(t, err)
+	data, err = eptest.Run(runner)
+	require.NoError(t, err)
+	require.Nil(t, data)
+}
+
+func TestValues_distributed(t *testing.T) {
+	port := ":5551"
+	dist := eptest.NewPeer(t, port)
+	defer eptest.ClosePeer(t, dist)
+
+	runner, err := ep.Values([]user{{ID: 1, Name: "alice"}})
+	require.NoError(t, err)
+
+	runner = dist.Distribute(runner, port)
+	data, err := eptest.Run(runner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"(1,alice,,0)"}, data.Strings())
+}
@@ -0,0 +1,98 @@
+package ep
+
+import (
+	"context"
+)
+
+// Tracer creates spans that trace the execution of Runners. Ep doesn't depend
+// on any specific tracing SDK, instead a Tracer adapts Ep's spans to the SDK
+// of choice. When a Tracer is enabled, a span is created for each Runner,
+// including exchanges, and for each fragment of a distributed Runner executed
+// on a remote peer. Remote spans are linked to the master's spans by sending
+// the SpanContext along with the distributed Runner.
+//
+//      ctx = ep.WithTracer(ctx, myTracer) // on master
+//      ep.NewDistributer(addr, ln, ep.WithTracing(myTracer)) // on peers
+type Tracer interface {
+	// StartSpan starts a new span with the provided name. The parent is the
+	// context of the enclosing span, possibly on another node, or a zero
+	// SpanContext for root spans
+	StartSpan(name string, parent SpanContext) Span
+}
+
+// Span is a single traced operation, created by a Tracer
+type Span interface {
+	// Context returns the identifiers of this span, used as the parent of its
+	// child spans
+	Context() SpanContext
+
+	// SetAttribute annotates the span with a key-value pair
+	SetAttribute(key, value string)
+
+	// End completes the span with the error it has ended with, if any
+	End(err error)
+}
+
+// SpanContext identifies a span across nodes. It's opaque to Ep, and only
+// transmitted between nodes as-is
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+// IsZero returns true if the SpanContext doesn't identify any span
+func (sc SpanContext) IsZero() bool { return sc == SpanContext{} }
+
+// NoopTracer is the default Tracer, that doesn't trace anything
+var NoopTracer Tracer = noopTracer{}
+
+type noopTracer struct{}
+type noopSpan struct{}
+
+func (noopTracer) StartSpan(string, SpanContext) Span { return noopSpan{} }
+func (noopSpan) Context() SpanContext                 { return SpanContext{} }
+func (noopSpan) SetAttribute(string, string)          {}
+func (noopSpan) End(error)                            {}
+
+// WithTracer returns a new context that traces all of the Runners executed
+// with it using the provided Tracer
+func WithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, tracer)
+}
+
+// WithTracing returns a DistributerOption that traces the Runners received
+// from other nodes using the provided Tracer, see Tracer
+func WithTracing(tracer Tracer) DistributerOption {
+	return func(d *distributer) {
+		d.tracer = tracer
+	}
+}
+
+// getTracer returns the Tracer of the context, or NoopTracer if tracing isn't
+// enabled
+func getTracer(ctx context.Context) Tracer {
+	tracer, ok := ctx.Value(tracerKey).(Tracer)
+	if !ok {
+		return NoopTracer
+	}
+	return tracer
+}
+
+// isTracing returns true if the context traces its Runners
+func isTracing(ctx context.Context) bool {
+	return getTracer(ctx) != NoopTracer
+}
+
+// spanContext returns the context of the current span, or a zero SpanContext
+// if there's none
+func spanContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanKey).(SpanContext)
+	return sc
+}
+
+// startSpan starts a new span as a child of the current span, and returns a
+// new context with the new span as the current one
+func startSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := getTracer(ctx).StartSpan(name, spanContext(ctx))
+	return context.WithValue(ctx, spanKey, span.Context()), span
+}
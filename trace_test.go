@@ -0,0 +1,99 @@
+package ep_test
+
+import (
+	"context"
+	"errors"
+	"github.com/panoplyio/ep"
+	"github.com/panoplyio/ep/eptest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWithTracer(t *testing.T) {
+	tracer := eptest.NewTracer()
+	ctx := ep.WithTracer(context.Background(), tracer)
+
+	runner := ep.Pipeline(&upper{}, &upper{})
+	_, err := eptest.RunWithContext(ctx, runner, ep.NewDataset(strs{"hello"}))
+	require.NoError(t, err)
+
+	spans := tracer.Spans()
+	require.Equal(t, 3, len(spans))
+
+	root := spans[0]
+	require.Equal(t, "pipeline", root.Name)
+	require.True(t, root.Parent.IsZero())
+
+	paths := []string{}
+	for _, span := range spans[1:] {
+		require.Equal(t, "ep_test.upper", span.Name)
+		require.Equal(t, root.Context(), span.Parent)
+		require.Equal(t, root.Context().TraceID, span.Context().TraceID)
+		paths = append(paths, span.Attributes["path"])
+	}
+	require.ElementsMatch(t, []string{"pipeline[0]", "pipeline[1]"}, paths)
+
+	for _, span := range spans {
+		require.True(t, span.Ended)
+		require.NoError(t, span.Err)
+	}
+}
+
+func TestWithTracer_error(t *testing.T) {
+	tracer := eptest.NewTracer()
+	ctx := ep.WithTracer(context.Background(), tracer)
+
+	failure := errors.New("something bad happened")
+	runner := ep.Pipeline(&upper{}, eptest.NewErrRunner(failure))
+	_, err := eptest.RunWithContext(ctx, runner, ep.NewDataset(strs{"hello"}))
+	require.Error(t, err)
+
+	for _, span := range tracer.Spans() {
+		if span.Attributes["path"] == "pipeline[1]" || span.Name == "pipeline" {
+			require.True(t, errors.Is(span.Err, failure))
+		}
+	}
+}
+
+func TestWithTracing_distributed(t *testing.T) {
+	tracer := eptest.NewTracer()
+	dist1 := eptest.NewPeer(t, ":5551", ep.WithTracing(tracer))
+	dist2 := eptest.NewPeer(t, ":5552", ep.WithTracing(tracer))
+	defer eptest.ClosePeer(t, dist1)
+	defer eptest.ClosePeer(t, dist2)
+
+	runner := ep.Pipeline(ep.Scatter(), &upper{}, ep.Gather())
+	runner = dist1.Distribute(runner, ":5551", ":5552")
+
+	ctx := ep.WithTracer(context.Background(), tracer)
+	data1 := ep.NewDataset(strs{"hello", "world"})
+	data2 := ep.NewDataset(strs{"foo", "bar"})
+	_, err := eptest.RunWithContext(ctx, runner, data1, data2)
+	require.NoError(t, err)
+
+	spans := tracer.Spans()
+	root := spans[0]
+	require.Equal(t, "distribute", root.Name)
+
+	var fragment *eptest.Span
+	byName := map[string]int{}
+	for i, span := range spans {
+		require.True(t, span.Ended, span.Name)
+		require.Equal(t, root.Context().TraceID, span.Context().TraceID, span.Name)
+		byName[span.Name]++
+		if span.Name == "fragment" {
+			fragment = &spans[i]
+		}
+	}
+
+	// the peer's fragment is linked to the master's span
+	require.NotNil(t, fragment)
+	require.Equal(t, root.Context(), fragment.Parent)
+	require.Equal(t, ":5552", fragment.Attributes["node"])
+
+	require.Equal(t, 1, byName["fragment"])
+	require.Equal(t, 2, byName["distribute"], "one on each node")
+	require.Equal(t, 2, byName["scatter"])
+	require.Equal(t, 2, byName["ep_test.upper"])
+	require.Equal(t, 2, byName["gather"])
+}
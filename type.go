@@ -125,3 +125,29 @@ func Modifier(t Type, k interface{}) interface{} {
 
 	return modifier.getModifier(k)
 }
+
+// UnderlyingType returns the type without any of its modifiers, like its
+// alias and nullability, in order to access the optional interfaces of the
+// type itself, like ScanTyper. See Modify
+func UnderlyingType(t Type) Type {
+	for {
+		modified, ok := t.(*modifierType)
+		if !ok {
+			return t
+		}
+		t = modified.Type
+	}
+}
+
+// SetNullable returns a new Type that declares whether the column may contain
+// nulls, as reported by the ColumnTypeNullable of Rows
+func SetNullable(col Type, nullable bool) Type {
+	return Modify(col, "Nullable", nullable)
+}
+
+// IsNullable returns whether the given typed column may contain nulls, or
+// false for ok if its nullability wasn't declared by SetNullable
+func IsNullable(col Type) (nullable, ok bool) {
+	nullable, ok = Modifier(col, "Nullable").(bool)
+	return nullable, ok
+}
@@ -79,9 +79,9 @@ func (rs union) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 	outputs := make([]chan Dataset, len(rs))
 	errors := make([]error, len(rs))
 	defer func() {
-		for _, errI := range errors {
+		for i, errI := range errors {
 			if errI != nil && err == nil {
-				err = errI
+				err = atRunner(errI, "union", i)
 				break
 			}
 		}
@@ -90,7 +90,7 @@ func (rs union) Run(ctx context.Context, inp, out chan Dataset) (err error) {
 		inputs[i] = make(chan Dataset)
 		outputs[i] = make(chan Dataset)
 
-		go Run(ctx, rs[i], inputs[i], outputs[i], nil, &errors[i])
+		go Run(withPath(ctx, "union", i), rs[i], inputs[i], outputs[i], nil, &errors[i])
 	}
 
 	// fork the input to all inner runners
@@ -126,6 +126,8 @@ func (rs union) Scopes() StringsSet {
 	return scopes
 }
 
+func (rs union) Idempotent() bool { return areIdempotent(rs) }
+
 func (rs union) ApproxSize() int {
 	var total int
 	for _, r := range rs {
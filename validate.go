@@ -0,0 +1,213 @@
+package ep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError describes an invalid runner found by Validate
+type ValidationError struct {
+	Path string // path of the invalid runner, see Stats
+	Msg  string
+}
+
+func (err *ValidationError) Error() string {
+	if err.Path == "" {
+		return "ep: invalid runner: " + err.Msg
+	}
+	return fmt.Sprintf("ep: invalid runner at %s: %s", err.Path, err.Msg)
+}
+
+// Validate statically verifies that the types flowing between the runners
+// composed in r are consistent, without running it. Wildcards are resolved
+// against the output of each preceding runner, which is then checked against
+// the Args() of every RunnerArgs, the branches of every Union, and the
+// columns of every Partition and SortGather. Types that are only known at
+// runtime (i.e. the input of r itself) are not verified. The first violation
+// found is returned as a *ValidationError
+func Validate(r Runner) (err error) {
+	// runners are free to panic on invalid compositions, see returns
+	defer func() {
+		if e := recover(); e != nil {
+			err = &ValidationError{Msg: fmt.Sprint(e)}
+		}
+	}()
+
+	_, err = validate(r, []Type{Wildcard}, "")
+	return err
+}
+
+// validate the runner for the provided input types, and return its resolved
+// output types
+func validate(r Runner, inp []Type, path string) ([]Type, error) {
+	at := func(step string) string {
+		if path == "" {
+			return step
+		}
+		return path + "/" + step
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return &ValidationError{path, fmt.Sprintf(format, args...)}
+	}
+
+	switch r := r.(type) {
+	case pipeline:
+		var err error
+		for i, child := range r {
+			inp, err = validate(child, inp, at(fmt.Sprintf("pipeline[%d]", i)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return inp, nil
+
+	case project:
+		var res []Type
+		for i, child := range r {
+			out, err := validate(child, inp, at(fmt.Sprintf("project[%d]", i)))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, out...)
+		}
+		return res, nil
+
+	case union:
+		var res []Type
+		for i, child := range r {
+			out, err := validate(child, inp, at(fmt.Sprintf("union[%d]", i)))
+			if err != nil {
+				return nil, err
+			}
+
+			if i == 0 {
+				res = out
+			} else if isResolved(res) && isResolved(out) && !AreEqualTypes(res, out) {
+				return nil, invalid("union branches return %s and %s", res, out)
+			}
+		}
+		return res, nil
+
+	case *distRunner:
+		return validate(r.Runner, inp, at("distribute"))
+
+	case *alias:
+		out, err := validate(r.Runner, inp, path)
+		if err != nil || !isResolved(out) {
+			return r.Returns(), err
+		}
+		if len(out) != 1 {
+			return nil, invalid("alias %s of %d columns, consider using scope", r.Label, len(out))
+		}
+		return []Type{SetAlias(out[0], r.Label)}, nil
+
+	case *scope:
+		out, err := validate(r.Runner, inp, path)
+		if err != nil || !isResolved(out) {
+			return r.Returns(), err
+		}
+		return SetScope(out, r.Label), nil
+
+	case *compose:
+		for _, cmp := range r.Cmps {
+			out, err := resolveReturns(cmp, inp)
+			if err != nil {
+				return nil, invalid("%s", err)
+			}
+			inp = out
+		}
+
+		if !isResolved(inp) {
+			return r.Returns(), nil
+		}
+		if r.Alias != "" {
+			if len(inp) != 1 {
+				return nil, invalid("alias %s of %d columns, consider using scope", r.Alias, len(inp))
+			}
+			inp = []Type{SetAlias(inp[0], r.Alias)}
+		}
+		return SetScope(inp, r.RetScope), nil
+
+	case *exchange:
+		if isResolved(inp) {
+			for _, col := range r.PartitionCols {
+				if col < 0 || col >= len(inp) {
+					return nil, invalid("%s on column %d out of %d columns", r.Type, col, len(inp))
+				}
+			}
+			for _, col := range r.SortingCols {
+				if col.Index < 0 || col.Index >= len(inp) {
+					return nil, invalid("%s on column %d out of %d columns", r.Type, col.Index, len(inp))
+				}
+			}
+		}
+	}
+
+	if withArgs, ok := r.(RunnerArgs); ok {
+		args := withArgs.Args()
+		if isResolved(inp) && isResolved(args) && !AreEqualTypes(args, inp) {
+			return nil, invalid("%s expects %s but receives %s", runnerName(r), args, inp)
+		}
+	}
+
+	out, err := resolveReturns(r, inp)
+	if err != nil {
+		return nil, invalid("%s", err)
+	}
+	return out, nil
+}
+
+// resolveReturns returns the output types of r, where wildcards are resolved
+// by the provided input types, see returnsOne
+func resolveReturns(r returns, inp []Type) ([]Type, error) {
+	out := r.Returns()
+	if !isResolved(inp) {
+		return out, nil // wildcards remain unknown
+	}
+
+	for _, t := range out {
+		w, isWildcard := t.(*wildcardType)
+		if !isWildcard {
+			continue
+		}
+
+		size := len(inp) - w.CutFromTail
+		if size < 0 {
+			return nil, fmt.Errorf("%s cuts %d columns out of %d", returnsName(r), w.CutFromTail, len(inp))
+		}
+		if w.Idx != nil && (*w.Idx < 0 || *w.Idx >= size) {
+			return nil, fmt.Errorf("%s returns column %d out of %d", returnsName(r), *w.Idx, size)
+		}
+	}
+
+	get := func(i int) returns {
+		if i == 0 {
+			return fixedReturns(inp)
+		}
+		return r
+	}
+	return returnsOne(1, get), nil
+}
+
+// returnsName returns the name of a runner or a Composable
+func returnsName(r returns) string {
+	if runner, ok := r.(Runner); ok {
+		return runnerName(runner)
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", r), "*")
+}
+
+// fixedReturns is a constant list of types, used to resolve wildcards
+type fixedReturns []Type
+
+func (ts fixedReturns) Returns() []Type { return ts }
+
+// isResolved returns true if the types don't depend on any unknown input types
+func isResolved(types []Type) bool {
+	for _, t := range types {
+		if _, isWildcard := t.(*wildcardType); isWildcard {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package ep_test
+
+import (
+	"context"
+	"errors"
+	"github.com/panoplyio/ep"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// typedArgs is a runner that expects specific input types
+type typedArgs struct{ Types []ep.Type }
+
+func (r *typedArgs) Equals(other interface{}) bool {
+	_, ok := other.(*typedArgs)
+	return ok
+}
+
+func (r *typedArgs) Args() []ep.Type    { return r.Types }
+func (r *typedArgs) Returns() []ep.Type { return []ep.Type{ep.Wildcard} }
+func (r *typedArgs) Run(_ context.Context, inp, out chan ep.Dataset) error {
+	for data := range inp {
+		out <- data
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	source := ep.PassThrough(str, integer)
+	union, err := ep.Union(ep.PassThrough(), ep.Pick(1))
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name   string
+		runner ep.Runner
+		path   string // of the invalid runner, empty when valid
+		msg    string
+	}{
+		{
+			name:   "valid",
+			runner: ep.Pipeline(source, &typedArgs{[]ep.Type{str, integer}}, ep.Partition(1), &upper{}),
+		},
+		{
+			name:   "unknown input",
+			runner: ep.Pipeline(&typedArgs{[]ep.Type{str}}, ep.Partition(3)),
+		},
+		{
+			name:   "args mismatch",
+			runner: ep.Pipeline(source, ep.Project(ep.PassThrough(), &typedArgs{[]ep.Type{integer, str}})),
+			path:   "pipeline[1]/project[1]",
+			msg:    "ep_test.typedArgs expects [integer string] but receives [string integer]",
+		},
+		{
+			name:   "args after wildcard resolution",
+			runner: ep.Pipeline(source, ep.Pick(0), &typedArgs{[]ep.Type{integer}}),
+			path:   "pipeline[2]",
+			msg:    "ep_test.typedArgs expects [integer] but receives [string]",
+		},
+		{
+			name:   "union mismatch",
+			runner: ep.Pipeline(source, union),
+			path:   "pipeline[1]",
+			msg:    "union branches return [string integer] and [integer]",
+		},
+		{
+			name:   "partition column",
+			runner: ep.Pipeline(source, ep.Partition(0, 2)),
+			path:   "pipeline[1]",
+			msg:    "partition on column 2 out of 2 columns",
+		},
+		{
+			name:   "sortGather column",
+			runner: ep.Pipeline(source, ep.SortGather([]ep.SortingCol{{Index: 5}})),
+			path:   "pipeline[1]",
+			msg:    "sortGather on column 5 out of 2 columns",
+		},
+		{
+			name:   "wildcard minus tail",
+			runner: ep.Pipeline(source, &tailCutter{3}),
+			path:   "pipeline[1]",
+			msg:    "ep_test.tailCutter cuts 3 columns out of 2",
+		},
+		{
+			name:   "alias of several columns",
+			runner: ep.Pipeline(source, ep.Alias(ep.PassThrough(), "a")),
+			path:   "pipeline[1]",
+			msg:    "alias a of 2 columns, consider using scope",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ep.Validate(tc.runner)
+			if tc.msg == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var validationErr *ep.ValidationError
+			require.True(t, errors.As(err, &validationErr), "%v", err)
+			require.Equal(t, tc.path, validationErr.Path)
+			require.Equal(t, tc.msg, validationErr.Msg)
+		})
+	}
+}
+
+func TestValidate_topLevel(t *testing.T) {
+	err := ep.Validate(ep.Alias(&typedArgs{}, "a"))
+	require.NoError(t, err, "output is unknown")
+
+	err = ep.Validate(ep.Alias(ep.PassThrough(str, integer), "a"))
+	require.Error(t, err)
+	require.Equal(t, "ep: invalid runner: alias a of 2 columns, consider using scope", err.Error())
+}